@@ -1,11 +1,9 @@
 package gobo
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/url"
+	"strings"
 )
 
 // Authenticator结构体实现了微博应用授权功能
@@ -15,6 +13,8 @@ type Authenticator struct {
 	clientSecret string
 	initialized  bool
 	httpClient   *http.Client
+	middlewares  []Middleware
+	baseURL      string
 }
 
 // 初始化结构体
@@ -29,11 +29,43 @@ func (auth *Authenticator) Init(redirectUri string, clientId string, clientSecre
 	auth.redirectUri = redirectUri
 	auth.clientId = clientId
 	auth.clientSecret = clientSecret
-	auth.httpClient = new(http.Client)
+	if auth.httpClient == nil {
+		auth.httpClient = new(http.Client)
+	}
 	auth.initialized = true
 	return nil
 }
 
+// 安装中间件，见Middleware类型的注释
+//
+// 中间件作用于AccessToken、GetTokenInfo和Revokeoauth2等访问授权服务器的函数。
+// 该函数应当在调用其它函数之前调用。
+func (auth *Authenticator) Use(middlewares ...Middleware) {
+	auth.middlewares = append(auth.middlewares, middlewares...)
+}
+
+// 设置访问授权服务器使用的HTTP客户端，比如使用自定义的Transport、代理或者超时
+//
+// 可以在Init之前或者之后调用。
+func (auth *Authenticator) SetHTTPClient(client *http.Client) {
+	auth.httpClient = client
+}
+
+// 设置授权服务器的地址，比如 "http://127.0.0.1:8080"，为空时使用ApiDomain（默认）
+//
+// 通常用于在测试中访问模拟的授权服务器，见gobotest.NewServer。可以在Init之前或者之后调用。
+func (auth *Authenticator) SetBaseURL(baseURL string) {
+	auth.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// 授权服务器的地址
+func (auth *Authenticator) domain() string {
+	if auth.baseURL != "" {
+		return auth.baseURL
+	}
+	return ApiDomain
+}
+
 // 得到授权URI
 func (auth *Authenticator) Authorize() (string, error) {
 	// 检查结构体是否初始化
@@ -41,7 +73,7 @@ func (auth *Authenticator) Authorize() (string, error) {
 		return "", &ErrorString{"Authenticator结构体尚未初始化"}
 	}
 
-	return fmt.Sprintf("%s/oauth2/authorize?redirect_uri=%s&response_type=code&client_id=%s", ApiDomain, auth.redirectUri, auth.clientId), nil
+	return fmt.Sprintf("%s/oauth2/authorize?redirect_uri=%s&response_type=code&client_id=%s", auth.domain(), auth.redirectUri, auth.clientId), nil
 }
 
 // 从授权码得到访问令牌
@@ -52,16 +84,17 @@ func (auth *Authenticator) AccessToken(code string) (AccessToken, error) {
 		return token, &ErrorString{"Authenticator结构体尚未初始化"}
 	}
 
-	// 生成请求URI
-	queries := url.Values{}
-	queries.Add("client_id", auth.clientId)
-	queries.Add("client_secret", auth.clientSecret)
-	queries.Add("redirect_uri", auth.redirectUri)
-	queries.Add("grant_type", "authorization_code")
-	queries.Add("code", code)
+	// 生成请求参数
+	params := Params{
+		"client_id":     auth.clientId,
+		"client_secret": auth.clientSecret,
+		"redirect_uri":  auth.redirectUri,
+		"grant_type":    "authorization_code",
+		"code":          code,
+	}
 
 	// 发送请求
-	err := auth.sendPostHttpRequest("oauth2/access_token", queries, &token)
+	err := auth.sendPostHttpRequest("oauth2/access_token", "", params, &token)
 	return token, err
 }
 
@@ -73,12 +106,8 @@ func (auth *Authenticator) GetTokenInfo(token string) (AccessTokenInfo, error) {
 		return info, &ErrorString{"Authenticator结构体尚未初始化"}
 	}
 
-	// 生成请求URI
-	queries := url.Values{}
-	queries.Add("access_token", token)
-
 	// 发送请求
-	err := auth.sendPostHttpRequest("oauth2/get_token_info", queries, &info)
+	err := auth.sendPostHttpRequest("oauth2/get_token_info", token, Params{}, &info)
 	return info, err
 }
 
@@ -89,44 +118,46 @@ func (auth *Authenticator) Revokeoauth2(token string) error {
 		return &ErrorString{"Authenticator结构体尚未初始化"}
 	}
 
-	// 生成请求URI
-	queries := url.Values{}
-	queries.Add("access_token", token)
-
 	// 发送请求
 	type Result struct {
 		Result string
 	}
 	var result Result
-	err := auth.sendPostHttpRequest("oauth2/revokeoauth2", queries, &result)
+	err := auth.sendPostHttpRequest("oauth2/revokeoauth2", token, Params{}, &result)
 	return err
 }
 
-func (auth *Authenticator) sendPostHttpRequest(apiName string, queries url.Values, response interface{}) error {
+// 通过中间件向授权服务器发送POST请求，token为空时不发送access_token参数
+func (auth *Authenticator) sendPostHttpRequest(apiName string, token string, params Params, response interface{}) error {
+	req := &Request{
+		Endpoint:   apiName,
+		HTTPMethod: "post",
+		Token:      token,
+		Params:     params,
+	}
+	resp, err := chainMiddlewares(auth.middlewares, auth.send)(req)
+	if err != nil {
+		return err
+	}
+	return resp.decode(response)
+}
+
+// 向授权服务器发送POST Form请求，位于中间件链的最内层
+func (auth *Authenticator) send(req *Request) (*Response, error) {
 	// 生成请求URI
-	requestUri := fmt.Sprintf("%s/%s", ApiDomain, apiName)
+	requestUri := fmt.Sprintf("%s/%s", auth.domain(), req.Endpoint)
+
+	// 生成POST Form内容
+	queries := req.Params.values()
+	if req.Token != "" {
+		queries.Set("access_token", req.Token)
+	}
 
 	// 发送POST Form请求
-	resp, err := auth.httpClient.PostForm(requestUri, queries)
+	httpReq, err := http.NewRequest("POST", requestUri, strings.NewReader(queries.Encode()))
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// 解析返回内容
-	bytes, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode == 200 {
-		err := json.Unmarshal(bytes, &response)
-		if err != nil {
-			return err
-		}
-	} else {
-		var weiboErr WeiboError
-		err := json.Unmarshal(bytes, &weiboErr)
-		if err != nil {
-			return err
-		}
-		return weiboErr
+		return nil, err
 	}
-	return nil
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doHttpRequest(auth.httpClient, httpReq)
 }
@@ -0,0 +1,268 @@
+package gobo
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache接口用来缓存API服务器的响应，见CacheMiddleware
+//
+// 实现必须可以被多个goroutine同时使用。
+type Cache interface {
+	// 取得key对应的响应，不存在或者已经过期时返回false
+	Get(key string) (*Response, bool)
+
+	// 缓存响应，ttl为有效期
+	Set(key string, resp *Response, ttl time.Duration)
+}
+
+// 默认的缓存有效期，键为API方法名，以"/"结尾的键匹配所有以其开头的API方法名
+var DefaultCacheTTLs = map[string]time.Duration{
+	"users/show":       5 * time.Minute,
+	"users/counts":     time.Minute,
+	"short_url/expand": 24 * time.Hour,
+	"common/":          24 * time.Hour,
+}
+
+// 缓存GET请求响应的中间件
+//
+// 输入参数
+//
+//	cache	缓存，比如NewLRUCache或者NewDiskCache的返回值
+//	ttls	各API方法的缓存有效期，格式见DefaultCacheTTLs，为nil时使用DefaultCacheTTLs
+//
+// 只有ttls中列出的API方法的GET请求被缓存，POST请求、HTTP状态码不是200的响应和微博错误永远不被缓存。
+// 缓存的键由API方法名、排序后的参数和访问令牌的哈希值组成，因此不同用户的响应不会混用，访问令牌本身也不会被保存。
+//
+// 为了让命中缓存的请求不受频率限制，该中间件应当安装在RateLimitMiddleware和RetryMiddleware之前，比如
+//
+//	weibo.Use(gobo.CacheMiddleware(gobo.NewLRUCache(1000), nil), gobo.RetryMiddleware(3, time.Second))
+func CacheMiddleware(cache Cache, ttls map[string]time.Duration) Middleware {
+	if ttls == nil {
+		ttls = DefaultCacheTTLs
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.HTTPMethod != "get" || req.Upload != nil {
+				return next(req)
+			}
+			ttl := cacheTTL(ttls, req.Endpoint)
+			if ttl <= 0 {
+				return next(req)
+			}
+
+			key := requestKey(req)
+			if resp, ok := cache.Get(key); ok {
+				return copyResponse(resp), nil
+			}
+			resp, err := next(req)
+			if err == nil && resp.StatusCode == 200 {
+				cache.Set(key, copyResponse(resp), ttl)
+			}
+			return resp, err
+		}
+	}
+}
+
+// 返回API方法的缓存有效期，没有列出的API方法返回0
+func cacheTTL(ttls map[string]time.Duration, endpoint string) time.Duration {
+	if ttl, ok := ttls[endpoint]; ok {
+		return ttl
+	}
+	// 前缀匹配时使用最长的前缀
+	var ttl time.Duration
+	longest := 0
+	for prefix, t := range ttls {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(endpoint, prefix) && len(prefix) > longest {
+			ttl = t
+			longest = len(prefix)
+		}
+	}
+	return ttl
+}
+
+// 生成标识请求的键：API方法名、排序后的参数和访问令牌的哈希值
+//
+// 参数的转换和实际发送的请求一致，见Params.values。
+func requestKey(req *Request) string {
+	return fmt.Sprintf("%s %s?%s#%s", req.HTTPMethod, req.Endpoint, canonicalParams(req.Params), tokenHash(req.Token))
+}
+
+// 将参数排序并编码成query string
+func canonicalParams(params Params) string {
+	return params.values().Encode()
+}
+
+// 访问令牌的哈希值，用来区分用户而不保存令牌本身
+func tokenHash(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func copyResponse(resp *Response) *Response {
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       append([]byte(nil), resp.Body...),
+	}
+}
+
+// LRUCache结构体是内存中的Cache实现，超过容量时淘汰最久未使用的响应
+type LRUCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  *list.List               // 元素为*lruEntry，最近使用的在最前面
+	index    map[string]*list.Element // 从键到entries中的元素
+}
+
+type lruEntry struct {
+	key     string
+	resp    *Response
+	expires time.Time
+}
+
+// 生成最多缓存capacity个响应的LRUCache
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		entries:  list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+// 实现Cache接口
+func (c *LRUCache) Get(key string) (*Response, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.entries.Remove(element)
+		delete(c.index, key)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	return entry.resp, true
+}
+
+// 实现Cache接口
+func (c *LRUCache) Set(key string, resp *Response, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires := time.Now().Add(ttl)
+	if element, ok := c.index[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.resp = resp
+		entry.expires = expires
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.index[key] = c.entries.PushFront(&lruEntry{key: key, resp: resp, expires: expires})
+	for c.entries.Len() > c.capacity {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.index, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// 返回缓存中的响应数（包括已经过期但尚未淘汰的）
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries.Len()
+}
+
+// DiskCache结构体是保存在磁盘上的Cache实现，程序重启后缓存仍然有效
+//
+// 每个响应保存为目录中的一个文件，文件名为键的SHA-256哈希值。过期的文件在读取时删除。
+type DiskCache struct {
+	dir string
+}
+
+// 磁盘上保存的内容
+type diskEntry struct {
+	Expires    time.Time   `json:"expires"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// 生成将响应保存在dir目录中的DiskCache，目录不存在时创建
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// 实现Cache接口
+func (c *DiskCache) Get(key string) (*Response, bool) {
+	path := c.path(key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		os.Remove(path)
+		return nil, false
+	}
+	if time.Now().After(entry.Expires) {
+		os.Remove(path)
+		return nil, false
+	}
+	return &Response{StatusCode: entry.StatusCode, Header: entry.Header, Body: entry.Body}, true
+}
+
+// 实现Cache接口，写入失败时忽略（相当于没有缓存）
+func (c *DiskCache) Set(key string, resp *Response, ttl time.Duration) {
+	data, err := json.Marshal(diskEntry{
+		Expires:    time.Now().Add(ttl),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       resp.Body,
+	})
+	if err != nil {
+		return
+	}
+	// 先写入临时文件再改名，避免其它goroutine或进程读到写了一半的文件
+	file, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+	if err := os.Rename(file.Name(), c.path(key)); err != nil {
+		os.Remove(file.Name())
+	}
+}
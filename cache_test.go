@@ -0,0 +1,142 @@
+package gobo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 通过CacheMiddleware并发请求，命中缓存的请求不访问服务器
+func TestCacheMiddleware(t *testing.T) {
+	diskCache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, cache := range map[string]Cache{"LRUCache": NewLRUCache(10), "DiskCache": diskCache} {
+		var hits int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&hits, 1)
+			if r.URL.Query().Get("uid") == "0" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"User does not exists!","error_code":20003,"request":"/2/users/show.json"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"id":%s,"screen_name":"%s"}`, r.URL.Query().Get("uid"), r.URL.Query().Get("access_token"))
+		}))
+
+		var weibo Weibo
+		weibo.SetBaseURL(server.URL)
+		weibo.Use(CacheMiddleware(cache, nil))
+		show := func(token string, uid int) (*User, error) {
+			var user User
+			err := weibo.Call("users/show", "get", token, Params{"uid": uid}, &user)
+			return &user, err
+		}
+		expectHits := func(what string, expected int64) {
+			t.Helper()
+			if n := atomic.LoadInt64(&hits); n != expected {
+				t.Errorf("%s：%s之后服务器收到%d个请求，期望%d个", name, what, n, expected)
+			}
+		}
+
+		if _, err := show("token-a", 1); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if user, err := show("token-a", 1); err != nil || user.Id != 1 || user.Screen_Name != "token-a" {
+					t.Errorf("%s：命中缓存时返回 %+v %v", name, user, err)
+				}
+			}()
+		}
+		wg.Wait()
+		expectHits("命中缓存", 1)
+
+		// 不同用户和不同参数的请求分别缓存
+		if user, err := show("token-b", 1); err != nil || user.Screen_Name != "token-b" {
+			t.Errorf("%s：另一个用户得到 %+v %v", name, user, err)
+		}
+		show("token-a", 2)
+		expectHits("不同的用户和参数", 3)
+
+		// 微博错误、POST请求和没有列出的API方法不缓存
+		show("token-a", 0)
+		show("token-a", 0)
+		weibo.Call("statuses/update", "post", "token-a", Params{"status": "你好"}, &Status{})
+		weibo.Call("statuses/update", "post", "token-a", Params{"status": "你好"}, &Status{})
+		weibo.Call("statuses/show", "get", "token-a", Params{"id": 1}, &Status{})
+		weibo.Call("statuses/show", "get", "token-a", Params{"id": 1}, &Status{})
+		expectHits("不缓存的请求", 9)
+		server.Close()
+	}
+
+	// 磁盘上不保存访问令牌
+	files, _ := filepath.Glob(filepath.Join(diskCache.dir, "*"))
+	if len(files) != 3 {
+		t.Errorf("DiskCache中有%d个文件，期望3个", len(files))
+	}
+	for _, file := range files {
+		data, _ := ioutil.ReadFile(file)
+		if strings.Contains(string(data), `"token-a"`) || strings.Contains(file, "token") {
+			t.Errorf("DiskCache的文件%s中出现了访问令牌", file)
+		}
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	resp := func(body string) *Response {
+		return &Response{StatusCode: 200, Body: []byte(body)}
+	}
+	cache.Set("a", resp("a"), time.Minute)
+	cache.Set("b", resp("b"), time.Minute)
+	cache.Get("a") // a成为最近使用的
+	cache.Set("c", resp("c"), time.Minute)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("最久未使用的b应当被淘汰")
+	}
+	for _, key := range []string{"a", "c"} {
+		if r, ok := cache.Get(key); !ok || string(r.Body) != key {
+			t.Errorf("Get(%q) = %v, %v", key, r, ok)
+		}
+	}
+
+	// 加入过期的响应时淘汰a，读取时删除过期的响应
+	cache.Set("expired", resp("expired"), -time.Second)
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("过期的响应不应当返回")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d，期望1", cache.Len())
+	}
+
+	// 并发读写
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprint((i + j) % 5)
+				cache.Set(key, resp(key), time.Minute)
+				if r, ok := cache.Get(key); ok && string(r.Body) != key {
+					t.Errorf("Get(%q)得到%q", key, r.Body)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if cache.Len() > 2 {
+		t.Errorf("Len() = %d，超过了容量", cache.Len())
+	}
+}
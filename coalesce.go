@@ -0,0 +1,71 @@
+package gobo
+
+import (
+	"sync"
+)
+
+// 合并相同的并发GET请求的中间件
+//
+// 当多个goroutine同时发出API方法名、参数和访问令牌都相同的GET请求时，只有第一个请求被发送，
+// 其它请求等待它完成并共享它的结果，从而节省调用配额。每个调用者得到响应的独立副本，
+// 并各自还原到自己的response中，因此调用者之间互不影响。POST请求和上传图片的请求不会被合并。
+//
+// 该中间件通常安装在CacheMiddleware之后、RetryMiddleware之前，比如
+//
+//	weibo.Use(gobo.CacheMiddleware(cache, nil), gobo.CoalesceMiddleware(), gobo.RetryMiddleware(3, time.Second))
+func CoalesceMiddleware() Middleware {
+	group := &callGroup{calls: make(map[string]*call)}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.HTTPMethod != "get" || req.Upload != nil {
+				return next(req)
+			}
+			resp, err := group.do(requestKey(req), func() (*Response, error) {
+				return next(req)
+			})
+			if err != nil {
+				return nil, err
+			}
+			return copyResponse(resp), nil
+		}
+	}
+}
+
+// callGroup记录进行中的请求
+type callGroup struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+// 一个进行中的请求
+type call struct {
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// 执行fn，同时进行的相同key的调用等待第一个调用的结果
+func (g *callGroup) do(key string, fn func() (*Response, error)) (*Response, error) {
+	g.mutex.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		<-c.done
+		return c.resp, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	defer func() {
+		// fn出现panic时也要唤醒等待的调用者
+		if c.resp == nil && c.err == nil {
+			c.err = &ErrorString{"合并的请求没有完成"}
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(c.done)
+	}()
+	c.resp, c.err = fn()
+	return c.resp, c.err
+}
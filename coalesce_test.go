@@ -0,0 +1,134 @@
+package gobo
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 同时发出的相同GET请求只调用一次next，每个调用者得到响应的独立副本
+func TestCoalesceMiddleware(t *testing.T) {
+	var calls int64
+	started := make(chan struct{}, 100)
+	release := make(chan struct{})
+	handler := CoalesceMiddleware()(func(req *Request) (*Response, error) {
+		atomic.AddInt64(&calls, 1)
+		started <- struct{}{}
+		<-release
+		if req.Endpoint == "statuses/show" {
+			return nil, errors.New("连接失败")
+		}
+		return &Response{StatusCode: 200, Body: []byte(`{"id":1}`)}, nil
+	})
+
+	// 并发发出请求，等待第一个请求到达next后再让它返回
+	run := func(n int, newRequest func(i int) *Request) ([]*Response, []error) {
+		resps := make([]*Response, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resps[i], errs[i] = handler(newRequest(i))
+			}(i)
+		}
+		<-started
+		time.Sleep(50 * time.Millisecond) // 让其它调用者进入等待
+		close(release)
+		wg.Wait()
+		release = make(chan struct{})
+		for len(started) > 0 {
+			<-started
+		}
+		return resps, errs
+	}
+
+	const n = 10
+	resps, errs := run(n, func(int) *Request {
+		return &Request{Endpoint: "users/show", HTTPMethod: "get", Token: "token", Params: Params{"uid": 1}}
+	})
+	if calls != 1 {
+		t.Errorf("相同的请求调用了%d次next，期望1次", calls)
+	}
+	for i := range resps {
+		if errs[i] != nil || resps[i] == nil || string(resps[i].Body) != `{"id":1}` {
+			t.Fatalf("第%d个调用者得到 %v %v", i, resps[i], errs[i])
+		}
+	}
+	resps[0].Body[0] = 'x'
+	if string(resps[1].Body) != `{"id":1}` {
+		t.Error("修改一个调用者的响应影响了其它调用者")
+	}
+
+	// 错误也由所有调用者共享
+	atomic.StoreInt64(&calls, 0)
+	_, errs = run(n, func(int) *Request {
+		return &Request{Endpoint: "statuses/show", HTTPMethod: "get", Token: "token", Params: Params{"id": 1}}
+	})
+	if calls != 1 {
+		t.Errorf("出错的请求调用了%d次next，期望1次", calls)
+	}
+	for i, err := range errs {
+		if err == nil || err.Error() != "连接失败" {
+			t.Errorf("第%d个调用者得到错误 %v", i, err)
+		}
+	}
+
+	// 访问令牌不同的GET请求和POST请求不合并
+	for name, newRequest := range map[string]func(int) *Request{
+		"不同用户": func(i int) *Request {
+			return &Request{Endpoint: "users/show", HTTPMethod: "get", Token: string(rune('a' + i)), Params: Params{"uid": 1}}
+		},
+		"POST": func(int) *Request {
+			return &Request{Endpoint: "statuses/update", HTTPMethod: "post", Token: "token", Params: Params{"status": "你好"}}
+		},
+	} {
+		atomic.StoreInt64(&calls, 0)
+		run(3, newRequest)
+		if calls != 3 {
+			t.Errorf("%s的请求调用了%d次next，期望3次", name, calls)
+		}
+	}
+}
+
+// 第一个调用者panic时，等待的调用者得到错误而不是一直阻塞
+func TestCoalesceMiddlewarePanic(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := CoalesceMiddleware()(func(req *Request) (*Response, error) {
+		close(started)
+		<-release
+		panic("出错了")
+	})
+	req := &Request{Endpoint: "users/show", HTTPMethod: "get", Token: "token"}
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		handler(req)
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := handler(req)
+		waiter <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // 让第二个调用者进入等待
+	close(release)
+
+	if p := <-panicked; p != "出错了" {
+		t.Errorf("第一个调用者得到 %v", p)
+	}
+	select {
+	case err := <-waiter:
+		if err == nil {
+			t.Error("等待的调用者应当得到错误")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待的调用者没有被唤醒")
+	}
+}
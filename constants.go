@@ -2,8 +2,22 @@ package gobo
 
 // 微博API相关的常数
 const (
-	ApiDomain      string = "https://api.weibo.com"
-	ApiVersion     string = "2"
-	UploadAPIName  string = "statuses/upload"
-	ApiNamePostfix string = ".json"
+	ApiDomain            string = "https://api.weibo.com"
+	ApiVersion           string = "2"
+	UploadAPIName        string = "statuses/upload"
+	UploadPicAPIName     string = "statuses/upload_pic"
+	UploadUrlTextAPIName string = "statuses/upload_url_text"
+	ApiNamePostfix       string = ".json"
+)
+
+// 微博和评论的字数限制，字数按照text.Length计算
+const (
+	MaxStatusLength  int = 140
+	MaxCommentLength int = 140
+)
+
+// 一条微博最多包含的图片数和每张图片的最大字节数
+const (
+	MaxPicsPerStatus int = 9
+	MaxImageBytes    int = 5 << 20
 )
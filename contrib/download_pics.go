@@ -0,0 +1,324 @@
+package contrib
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/huichen/gobo"
+)
+
+const (
+	// 下载目录中记录已下载图片的清单文件，每行为 "URL\t文件名"
+	PICS_MANIFEST = "manifest.txt"
+
+	// 未下载完成的图片的扩展名
+	PICS_PARTIAL_EXT = ".part"
+
+	// DownloadPics的client为nil时，每张图片下载的超时时间
+	PICS_DOWNLOAD_TIMEOUT = 60 * time.Second
+)
+
+// DownloadResult为DownloadPics的下载结果
+type DownloadResult struct {
+	Files  map[string]string // 图片URL到本地文件路径的映射，包括之前已经下载过的图片
+	Errors map[string]error  // 下载失败的图片URL及其错误
+	Bytes  int64             // 本次下载的总字节数
+}
+
+// 超出字节预算时的错误
+var errBudgetExceeded = &gobo.ErrorString{S: "超出下载字节预算"}
+
+// 并行下载图片到指定目录
+//
+// 输入参数：
+//
+//	client		发送请求使用的HTTP客户端，比如gobotest.Recorder.Client的返回值；为nil时使用超时为PICS_DOWNLOAD_TIMEOUT的客户端
+//	urls		图片URL，可以通过gobo.Status.Pics得到
+//	dir		下载目录，不存在时自动创建
+//	numThreads	并行下载的线程数，当值小于等于0时使用MAX_THREADS
+//	byteBudget	本次下载的字节数上限，当值为0时不设上限；超出上限后未完成的下载被中止，可以在下次调用时续传
+//
+// 图片以内容的SHA-256命名（即content-addressed），内容相同的图片只保存一份。
+// 已下载的图片记录在下载目录的PICS_MANIFEST文件中，再次调用时不会重复下载；
+// 未完成的下载保存为PICS_PARTIAL_EXT文件，再次调用时通过HTTP Range续传。
+//
+// 只有在下载目录或清单文件无法访问时返回非nil错误，单张图片的错误见DownloadResult.Errors。
+func DownloadPics(client *http.Client, urls []string, dir string, numThreads int, byteBudget int64) (*DownloadResult, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: PICS_DOWNLOAD_TIMEOUT}
+	}
+	downloader := &picDownloader{
+		client:     client,
+		dir:        dir,
+		byteBudget: byteBudget,
+		result: &DownloadResult{
+			Files:  make(map[string]string),
+			Errors: make(map[string]error),
+		},
+	}
+	if err := downloader.loadManifest(); err != nil {
+		return nil, err
+	}
+	manifest, err := os.OpenFile(filepath.Join(dir, PICS_MANIFEST), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer manifest.Close()
+	downloader.manifest = manifest
+
+	// 去掉重复和已经下载过的URL
+	pending := make(chan string, len(urls))
+	queued := make(map[string]bool)
+	for _, u := range urls {
+		if _, ok := downloader.result.Files[u]; ok || queued[u] {
+			continue
+		}
+		queued[u] = true
+		pending <- u
+	}
+	close(pending)
+
+	if numThreads <= 0 {
+		numThreads = MAX_THREADS
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range pending {
+				downloader.download(u)
+			}
+		}()
+	}
+	wg.Wait()
+	return downloader.result, nil
+}
+
+type picDownloader struct {
+	client     *http.Client
+	dir        string
+	byteBudget int64
+	bytes      int64 // 本次已下载的字节数，原子访问
+	manifest   *os.File
+
+	sync.Mutex // 保护result和manifest
+	result     *DownloadResult
+}
+
+// 从清单文件中读取已经下载过的图片，忽略文件已经不存在的记录
+func (d *picDownloader) loadManifest() error {
+	file, err := os.Open(filepath.Join(d.dir, PICS_MANIFEST))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		picFile := filepath.Join(d.dir, fields[1])
+		if _, err := os.Stat(picFile); err == nil {
+			d.result.Files[fields[0]] = picFile
+		}
+	}
+	return scanner.Err()
+}
+
+// 下载一张图片并记录结果
+func (d *picDownloader) download(u string) {
+	file, err := d.fetch(u)
+
+	d.Lock()
+	defer d.Unlock()
+	if err != nil {
+		d.result.Errors[u] = err
+		return
+	}
+	d.result.Files[u] = file
+	if _, err := fmt.Fprintf(d.manifest, "%s\t%s\n", u, filepath.Base(file)); err != nil {
+		d.result.Errors[u] = err
+	}
+}
+
+// 下载图片到临时文件（支持续传），完成后以内容的哈希值重命名，返回文件路径
+func (d *picDownloader) fetch(u string) (string, error) {
+	if d.byteBudget > 0 && atomic.LoadInt64(&d.bytes) >= d.byteBudget {
+		return "", errBudgetExceeded
+	}
+
+	urlHash := sha1.Sum([]byte(u))
+	partial := filepath.Join(d.dir, hex.EncodeToString(urlHash[:])+PICS_PARTIAL_EXT)
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+
+	// 发送请求，有未完成的下载时请求剩余部分
+	resp, err := d.get(u, offset)
+	if err != nil {
+		return "", err
+	}
+	if offset > 0 && !rangeMatches(resp, offset) {
+		// 服务器返回的范围和本地文件不一致（比如图片已经改变），丢弃本地内容从头下载
+		resp.Body.Close()
+		offset = 0
+		if resp, err = d.get(u, 0); err != nil {
+			return "", err
+		}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+	case resp.StatusCode == http.StatusOK:
+		// 服务器不支持续传，从头下载
+		if err := file.Truncate(0); err != nil {
+			return "", err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// 之前已经下载完整
+	default:
+		return "", &gobo.ErrorString{S: fmt.Sprintf("下载图片失败，HTTP状态码%d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		if _, err := io.Copy(file, &budgetReader{resp.Body, d}); err != nil {
+			return "", err
+		}
+	}
+
+	// 计算内容的哈希值作为文件名
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	hash.Write(head[:n])
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	target := filepath.Join(d.dir, hex.EncodeToString(hash.Sum(nil))+picExtension(u, head[:n]))
+	file.Close()
+
+	// 内容相同的图片已经存在时删除临时文件
+	if _, err := os.Stat(target); err == nil {
+		return target, os.Remove(partial)
+	}
+	return target, os.Rename(partial, target)
+}
+
+// 发送GET请求，offset大于0时只请求从offset开始的部分
+func (d *picDownloader) get(u string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return d.client.Do(req)
+}
+
+// 检查续传请求的响应是否和已经下载的offset个字节一致
+//
+// 206响应的Content-Range必须从offset开始；416响应的Content-Range（"bytes */图片大小"）必须等于offset，
+// 即之前已经下载完整。其它状态码不需要检查。
+func rangeMatches(resp *http.Response, offset int64) bool {
+	start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return ok && start == offset
+	case http.StatusRequestedRangeNotSatisfiable:
+		return ok && start < 0 && size == offset
+	}
+	return true
+}
+
+// 解析Content-Range响应头，比如 "bytes 100-199/200" 或者 "bytes */200"
+//
+// 返回范围的起始位置和内容的总大小，没有起始位置（"*"）时start为-1，总大小未知（"*"）时size为-1。
+func parseContentRange(header string) (start int64, size int64, ok bool) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	start, size = -1, -1
+	var err error
+	if fields[0] != "*" {
+		bounds := strings.SplitN(fields[0], "-", 2)
+		if len(bounds) != 2 {
+			return 0, 0, false
+		}
+		if start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if fields[1] != "*" {
+		if size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
+
+// 得到图片的扩展名，优先根据内容识别
+func picExtension(u string, head []byte) string {
+	if _, extension, err := gobo.DetectImageFormat(head); err == nil {
+		return "." + extension
+	}
+	if extension := path.Ext(strings.SplitN(u, "?", 2)[0]); extension != "" && len(extension) <= 5 {
+		return extension
+	}
+	return ".bin"
+}
+
+// budgetReader在读取时累计下载的字节数，超出预算时返回错误
+type budgetReader struct {
+	reader     io.Reader
+	downloader *picDownloader
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	total := atomic.AddInt64(&r.downloader.bytes, int64(n))
+	r.downloader.Lock()
+	r.downloader.result.Bytes = total
+	r.downloader.Unlock()
+	if r.downloader.byteBudget > 0 && total > r.downloader.byteBudget {
+		return n, errBudgetExceeded
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package contrib
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 本地有未完成的下载时，根据服务器返回的Content-Range续传或者从头下载
+func TestDownloadPicsResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	cases := []struct {
+		name    string
+		partial []byte // 本地未完成的下载
+		handler func(w http.ResponseWriter, r *http.Request)
+		bytes   int64 // 期望本次下载的字节数
+	}{
+		{"续传", content[:300], serveContent(content), int64(len(content) - 300)},
+		{"已经下载完整", content, serveContent(content), 0},
+		{"服务器不支持续传", content[:300], func(w http.ResponseWriter, r *http.Request) {
+			w.Write(content)
+		}, int64(len(content))},
+		// 本地文件比服务器上的图片长，服务器返回416，其中的大小和本地文件不一致
+		{"本地文件过长", append(append([]byte{}, content...), "多余的内容"...), serveContent(content), int64(len(content))},
+		// 服务器返回的206从错误的位置开始
+		{"范围不一致", content[:300], func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") == "" {
+				w.Write(content)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content)
+		}, int64(len(content))},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(c.handler))
+		u := server.URL + "/pic.jpg"
+		dir := t.TempDir()
+		urlHash := sha1.Sum([]byte(u))
+		partial := filepath.Join(dir, hex.EncodeToString(urlHash[:])+PICS_PARTIAL_EXT)
+		if err := ioutil.WriteFile(partial, c.partial, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := DownloadPics(nil, []string{u}, dir, 1, 0)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s：%v", c.name, err)
+		}
+		if err := result.Errors[u]; err != nil {
+			t.Errorf("%s：下载出错：%v", c.name, err)
+			continue
+		}
+		data, err := ioutil.ReadFile(result.Files[u])
+		if err != nil {
+			t.Errorf("%s：%v", c.name, err)
+			continue
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s：下载的内容为%d字节，和服务器上的图片不同", c.name, len(data))
+		}
+		if result.Bytes != c.bytes {
+			t.Errorf("%s：本次下载了%d字节，期望%d字节", c.name, result.Bytes, c.bytes)
+		}
+	}
+}
+
+func serveContent(content []byte) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "pic.jpg", time.Time{}, bytes.NewReader(content))
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := []struct {
+		header      string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-99/*", 0, -1, true},
+		{"bytes */200", -1, 200, true},
+		{"bytes 100/200", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, c := range cases {
+		start, size, ok := parseContentRange(c.header)
+		if start != c.start || size != c.size || ok != c.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v，期望 %d, %d, %v", c.header, start, size, ok, c.start, c.size, c.ok)
+		}
+	}
+}
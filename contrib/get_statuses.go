@@ -22,7 +22,8 @@ const (
 //	numStatuses	需要抓取的总微博数，注意由于新浪的限制，最多只能抓取最近2000条微博，当此参数大于2000时取2000
 //      timeout		超时退出，单位为毫秒，当值为0时不设超时
 //
-// 返回按照ID逆序排序的微博
+// 返回按照ID逆序排序的微博。如果weibo设置了SetExpandLongText，其中的长微博已经被展开，
+// 展开失败的微博只有截断的Text，见Status.LongTextError
 func GetStatuses(weibo *gobo.Weibo, access_token string, userName string, userId int64, numStatuses int, timeout int) ([]*gobo.Status, error) {
 	// 检查输入参数的有效性
 	if userName == "" && userId == 0 {
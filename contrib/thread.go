@@ -0,0 +1,201 @@
+package contrib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/huichen/gobo"
+	"github.com/huichen/gobo/text"
+)
+
+// 长文后续部分的发布方式
+const (
+	THREAD_COMMENT = iota // 后续部分作为评论发布，每条评论回复前一条评论
+	THREAD_REPOST         // 后续部分作为转发发布，每条转发转发前一条微博
+)
+
+// 句子结束的标点，优先在这些位置拆分
+const sentenceDelimiters = "。！？!?；;…\n"
+
+// 句子内部的标点和空白，当一个句子超长时在这些位置拆分
+const clauseDelimiters = "，,、：: \t"
+
+// ThreadError在PostThread部分失败时返回
+type ThreadError struct {
+	Index  int       // 发布失败的部分的序号（从0开始）
+	Posted []gobo.ID // 已经成功发布的部分的ID，可以作为PostThread的posted参数续传
+	Err    error     // 微博API返回的错误
+}
+
+func (e *ThreadError) Error() string {
+	return fmt.Sprintf("Gobo错误：长文第%d部分发布失败（已发布%d部分）：%v", e.Index+1, len(e.Posted), e.Err)
+}
+
+// 将长文拆分为不超过limit个字的若干部分，并加上形如 "(1/3) " 的编号
+//
+// 拆分优先发生在句末标点（中英文）和换行处，其次是逗号等句内标点和空白处，
+// 实在无法拆分时按字数硬拆，但不会拆开链接、@提及、#话题#和[表情]。
+// 只有一部分时不加编号。
+func SplitThread(content string, limit int) []string {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+	if text.Length(content) <= limit {
+		return []string{content}
+	}
+
+	// 为编号预留位置：编号的位数取决于总部数，因此从1位开始尝试
+	for digits := 1; ; digits++ {
+		maxParts := 1
+		for i := 0; i < digits; i++ {
+			maxParts *= 10
+		}
+		widest := strings.Repeat("9", digits)
+		reserved := text.Length(fmt.Sprintf("(%s/%s) ", widest, widest))
+		if reserved >= limit {
+			return nil
+		}
+
+		parts := splitByLength(content, limit-reserved)
+		if len(parts) < maxParts {
+			for i := range parts {
+				parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), parts[i])
+			}
+			return parts
+		}
+	}
+}
+
+// 发布拆分好的长文
+//
+// 输入参数：
+//
+//	weibo		gobo.Weibo结构体指针
+//	access_token	用户的访问令牌
+//	parts		SplitThread的输出
+//	mode		THREAD_COMMENT或THREAD_REPOST
+//	posted		上一次调用部分失败时ThreadError.Posted的值，用于续传；首次发布时为nil
+//
+// 第一部分通过statuses/update发布，之后的部分按照mode作为评论或者转发依次链接到前一部分。
+// 返回所有部分的ID：THREAD_COMMENT模式下第一个是微博ID，其余是评论ID；THREAD_REPOST模式下都是微博ID。
+// 部分失败时返回*ThreadError，其中包含已经发布的部分的ID。
+func PostThread(weibo *gobo.Weibo, access_token string, parts []string, mode int, posted []gobo.ID) ([]gobo.ID, error) {
+	if mode != THREAD_COMMENT && mode != THREAD_REPOST {
+		return nil, &gobo.ErrorString{S: "无效的长文发布方式"}
+	}
+	if len(posted) > len(parts) {
+		return nil, &gobo.ErrorString{S: "已发布的部分多于长文的总部数"}
+	}
+
+	ids := append(make([]gobo.ID, 0, len(parts)), posted...)
+	for i := len(ids); i < len(parts); i++ {
+		id, err := postThreadPart(weibo, access_token, parts[i], mode, ids)
+		if err != nil {
+			return ids, &ThreadError{Index: i, Posted: ids, Err: err}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// 发布长文的一部分，ids为已经发布的部分的ID
+func postThreadPart(weibo *gobo.Weibo, access_token string, part string, mode int, ids []gobo.ID) (gobo.ID, error) {
+	// 第一部分
+	if len(ids) == 0 {
+		var status gobo.Status
+		err := weibo.Call("statuses/update", "post", access_token, gobo.Params{"status": part}, &status)
+		return status.Id, err
+	}
+
+	// 转发前一条微博
+	previous := ids[len(ids)-1]
+	if mode == THREAD_REPOST {
+		var status gobo.Status
+		err := weibo.Call("statuses/repost", "post", access_token, gobo.Params{"id": previous, "status": part}, &status)
+		return status.Id, err
+	}
+
+	// 评论第一条微博，或者回复前一条评论
+	var comment gobo.Comment
+	var err error
+	if len(ids) == 1 {
+		err = weibo.Call("comments/create", "post", access_token, gobo.Params{"id": ids[0], "comment": part}, &comment)
+	} else {
+		params := gobo.Params{"id": ids[0], "cid": previous, "comment": part, "without_mention": 1}
+		err = weibo.Call("comments/reply", "post", access_token, params, &comment)
+	}
+	return comment.Id, err
+}
+
+// 将正文拆分为每部分不超过limit个字
+func splitByLength(content string, limit int) []string {
+	parts := make([]string, 0)
+	var current string
+	for _, sentence := range splitAfter(content, sentenceDelimiters) {
+		for _, piece := range fitPieces(sentence, limit) {
+			if current != "" && text.Length(current+piece) > limit {
+				parts = appendPart(parts, current)
+				current = ""
+			}
+			current += piece
+		}
+	}
+	return appendPart(parts, current)
+}
+
+// 将超长的句子拆分为不超过limit个字的片段，短句原样返回
+func fitPieces(sentence string, limit int) []string {
+	if text.Length(sentence) <= limit {
+		return []string{sentence}
+	}
+
+	pieces := make([]string, 0)
+	for _, clause := range splitAfter(sentence, clauseDelimiters) {
+		// 在没有标点的超长片段中硬拆
+		for text.Length(clause) > limit {
+			head := text.Truncate(clause, limit, "")
+			if head == "" {
+				// 单个实体（比如超长的链接）就超过了limit，只能整个保留
+				head = text.Tokenize(clause)[0].Text
+			}
+			pieces = append(pieces, head)
+			clause = clause[len(head):]
+		}
+		if clause != "" {
+			pieces = append(pieces, clause)
+		}
+	}
+	return pieces
+}
+
+// 在delimiters中的任一字符之后拆分字符串，不会拆开链接、@提及、#话题#和[表情]
+func splitAfter(s string, delimiters string) []string {
+	result := make([]string, 0)
+	var current strings.Builder
+	for _, token := range text.Tokenize(s) {
+		if token.Type != text.Plain {
+			current.WriteString(token.Text)
+			continue
+		}
+		for _, r := range token.Text {
+			current.WriteRune(r)
+			if strings.ContainsRune(delimiters, r) {
+				result = append(result, current.String())
+				current.Reset()
+			}
+		}
+	}
+	if current.Len() > 0 {
+		result = append(result, current.String())
+	}
+	return result
+}
+
+func appendPart(parts []string, part string) []string {
+	part = strings.TrimSpace(part)
+	if part == "" {
+		return parts
+	}
+	return append(parts, part)
+}
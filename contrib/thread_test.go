@@ -0,0 +1,151 @@
+package contrib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/huichen/gobo"
+	"github.com/huichen/gobo/gobotest"
+	"github.com/huichen/gobo/text"
+)
+
+func TestSplitThread(t *testing.T) {
+	cases := []struct {
+		content string
+		limit   int
+		parts   []string
+	}{
+		{"", 10, nil},
+		{"  \n ", 10, nil},
+		// 不超过limit时不拆分，也不加编号
+		{"  没有超过  ", 10, []string{"没有超过"}},
+		{"abc", 2, []string{"abc"}},
+		// 在句末标点处拆分
+		{"第一句话。第二句话！第三句话？", 10, []string{"(1/3) 第一句话。", "(2/3) 第二句话！", "(3/3) 第三句话？"}},
+		// 没有标点时按字数硬拆，编号计入字数
+		{"一二三四五六七八九十一二三四五六七八九十", 10, []string{"(1/3) 一二三四五六七", "(2/3) 八九十一二三四", "(3/3) 五六七八九十"}},
+		// 不拆开@提及和#话题#
+		{"@人民日报新闻频道 你好，#今日话题很长很长# 再见", 12, []string{
+			"(1/4) @人民日报新闻频道", "(2/4) 你好，", "(3/4) #今日话题很长很长#", "(4/4) 再见"}},
+		// 单个链接就超过了limit时整个保留
+		{"看这个链接http://example.com/a/very/long/path/that/keeps/going 很有意思", 12, []string{
+			"(1/3) 看这个链接", "(2/3) http://example.com/a/very/long/path/that/keeps/going", "(3/3) 很有意思"}},
+		// limit放不下编号
+		{"一二三四五六七八九十", 3, nil},
+	}
+	for _, c := range cases {
+		if parts := SplitThread(c.content, c.limit); !reflect.DeepEqual(parts, c.parts) {
+			t.Errorf("SplitThread(%q, %d) = %q，期望 %q", c.content, c.limit, parts, c.parts)
+		}
+	}
+}
+
+// 对不同的limit检查拆分结果：每部分不超过limit，编号正确，内容完整，并且实体没有被拆开
+func TestSplitThreadBoundaries(t *testing.T) {
+	content := strings.Repeat("转发@人民日报新闻：#今日话题# 详见http://t.cn/RxLz2hE [哈哈]，"+
+		"这是一段没有标点的很长很长很长很长很长很长很长的文字！English words, too; ", 12)
+	entities := make(map[string]bool)
+	for _, entity := range text.Parse(content) {
+		entities[entity.Text] = true
+	}
+
+	for _, limit := range []int{12, 20, 33, 70, 140} {
+		parts := SplitThread(content, limit)
+		if len(parts) < 2 {
+			t.Fatalf("limit为%d时拆分为%d部分", limit, len(parts))
+		}
+		var joined strings.Builder
+		for i, part := range parts {
+			prefix := fmt.Sprintf("(%d/%d) ", i+1, len(parts))
+			if !strings.HasPrefix(part, prefix) {
+				t.Errorf("limit为%d时第%d部分%q的编号不是%q", limit, i, part, prefix)
+				continue
+			}
+			body := strings.TrimPrefix(part, prefix)
+			// 只有单个实体就超过了limit时才允许超过
+			if tokens := text.Tokenize(body); text.Length(part) > limit && (len(tokens) != 1 || tokens[0].Type == text.Plain) {
+				t.Errorf("limit为%d时第%d部分%q超过了limit", limit, i, part)
+			}
+			for _, entity := range text.Parse(body) {
+				if !entities[entity.Text] {
+					t.Errorf("limit为%d时第%d部分%q中的实体%q被拆开了", limit, i, part, entity.Text)
+				}
+			}
+			joined.WriteString(body)
+		}
+		if removeSpaces(joined.String()) != removeSpaces(content) {
+			t.Errorf("limit为%d时拼接各部分后和原文不同", limit)
+		}
+	}
+}
+
+func removeSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// 发布到一半超过频率限制，返回的ThreadError可以用于续传
+func TestPostThread(t *testing.T) {
+	server := gobotest.NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	token := server.IssueToken(alice)
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+
+	parts := []string{"(1/4) 第一部分", "(2/4) 第二部分", "(3/4) 第三部分", "(4/4) 第四部分"}
+	server.SetRateLimit(2)
+	ids, err := PostThread(&weibo, token, parts, THREAD_COMMENT, nil)
+	threadErr, ok := err.(*ThreadError)
+	if !ok {
+		t.Fatalf("期望*ThreadError，得到 %v", err)
+	}
+	if threadErr.Index != 2 || len(threadErr.Posted) != 2 || !reflect.DeepEqual(ids, threadErr.Posted) {
+		t.Errorf("ThreadError为 %+v，返回的ID为 %v", threadErr, ids)
+	}
+	if weiboErr, ok := threadErr.Err.(gobo.WeiboError); !ok || weiboErr.Error_Code != gobotest.ErrorRateLimit {
+		t.Errorf("ThreadError.Err为 %v，期望频率限制错误", threadErr.Err)
+	}
+	if !strings.Contains(threadErr.Error(), "第3部分") {
+		t.Errorf("ThreadError.Error() = %q", threadErr.Error())
+	}
+
+	// 续传剩下的部分
+	server.SetRateLimit(0)
+	ids, err = PostThread(&weibo, token, parts, THREAD_COMMENT, threadErr.Posted)
+	if err != nil {
+		t.Fatalf("续传出错：%v", err)
+	}
+	if len(ids) != len(parts) || !reflect.DeepEqual(ids[:2], threadErr.Posted) {
+		t.Fatalf("续传后的ID为 %v", ids)
+	}
+
+	// 第一部分是微博，之后的部分是依次回复的评论
+	var status gobo.Status
+	if err := weibo.Call("statuses/show", "get", token, gobo.Params{"id": ids[0]}, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Text != parts[0] || status.Comments_Count != 3 {
+		t.Errorf("第一部分为 %+v", status)
+	}
+	var comments struct {
+		Comments []*gobo.Comment `json:"comments"`
+	}
+	if err := weibo.Call("comments/show", "get", token, gobo.Params{"id": ids[0]}, &comments); err != nil {
+		t.Fatal(err)
+	}
+	if len(comments.Comments) != 3 {
+		t.Fatalf("得到%d条评论，期望3条", len(comments.Comments))
+	}
+	for i, comment := range comments.Comments {
+		// 新的评论在前
+		n := len(parts) - 1 - i
+		if comment.Id != ids[n] || comment.Text != parts[n] {
+			t.Errorf("第%d部分为 %+v", n, comment)
+		}
+		if hasReply := comment.Reply_Comment != nil; hasReply != (n > 1) || hasReply && comment.Reply_Comment.Id != ids[n-1] {
+			t.Errorf("第%d部分回复的评论为 %+v", n, comment.Reply_Comment)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package gobo
+
+import (
+	"github.com/huichen/gobo/text"
+)
+
+// 从微博正文中提取@提及、#话题#、链接和[表情]等实体，见text包
+func (status *Status) Entities() []text.Entity {
+	return text.Parse(status.Text)
+}
+
+// 从评论正文中提取@提及、#话题#、链接和[表情]等实体，见text包
+func (comment *Comment) Entities() []text.Entity {
+	return text.Parse(comment.Text)
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -77,7 +78,7 @@ func uploadStatus() {
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = weibo.Upload(*access_token, params, img, filepath.Ext(*image), &status)
+	err = weibo.Upload(*access_token, params, img, strings.TrimPrefix(filepath.Ext(*image), "."), &status)
 	if err != nil {
 		fmt.Println(err)
 	} else {
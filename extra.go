@@ -0,0 +1,137 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 实现json.Unmarshaler接口，没有对应字段的JSON字段保存在Extra中
+func (status *Status) UnmarshalJSON(data []byte) error {
+	type plain Status
+	if err := json.Unmarshal(data, (*plain)(status)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, reflect.TypeOf(*status))
+	status.Extra = extra
+	return err
+}
+
+// 实现json.Marshaler接口，Extra中的字段原样输出
+func (status Status) MarshalJSON() ([]byte, error) {
+	type plain Status
+	return marshalWithExtra((*plain)(&status), status.Extra)
+}
+
+// 实现json.Unmarshaler接口，没有对应字段的JSON字段保存在Extra中
+func (comment *Comment) UnmarshalJSON(data []byte) error {
+	type plain Comment
+	if err := json.Unmarshal(data, (*plain)(comment)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, reflect.TypeOf(*comment))
+	comment.Extra = extra
+	return err
+}
+
+// 实现json.Marshaler接口，Extra中的字段原样输出
+func (comment Comment) MarshalJSON() ([]byte, error) {
+	type plain Comment
+	return marshalWithExtra((*plain)(&comment), comment.Extra)
+}
+
+// 实现json.Unmarshaler接口，没有对应字段的JSON字段保存在Extra中
+func (user *User) UnmarshalJSON(data []byte) error {
+	type plain User
+	if err := json.Unmarshal(data, (*plain)(user)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, reflect.TypeOf(*user))
+	user.Extra = extra
+	return err
+}
+
+// 实现json.Marshaler接口，Extra中的字段原样输出
+func (user User) MarshalJSON() ([]byte, error) {
+	type plain User
+	return marshalWithExtra((*plain)(&user), user.Extra)
+}
+
+// 每个结构体的json标签中的字段名（小写），键为reflect.Type
+var knownFieldsCache sync.Map
+
+// 返回结构体t的所有JSON字段名，小写以便和encoding/json一样不区分大小写地匹配
+func knownFields(t reflect.Type) map[string]bool {
+	if fields, ok := knownFieldsCache.Load(t); ok {
+		return fields.(map[string]bool)
+	}
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = true
+	}
+	knownFieldsCache.Store(t, fields)
+	return fields
+}
+
+// 返回data中没有对应于结构体t的字段的JSON字段，没有时返回nil
+func unknownFields(data []byte, t reflect.Type) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := knownFields(t)
+	var extra map[string]json.RawMessage
+	for key, value := range all {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[key] = value
+	}
+	return extra, nil
+}
+
+// 编码v，并将extra中的字段按照字段名的顺序追加在后面
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	buffer.Write(data[:len(data)-1]) // 去掉最后的 '}'
+	empty := len(data) == 2
+	for _, key := range keys {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			buffer.WriteByte(',')
+		}
+		empty = false
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(extra[key])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
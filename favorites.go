@@ -0,0 +1,168 @@
+package gobo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 每次请求最多删除的收藏或者标签数
+const MaxFavoritesPerBatch = 20
+
+// ListAll每页抓取的收藏数
+const favoritesPerPage = 50
+
+// FavoritesService封装了收藏相关的API，通过Weibo.Favorites得到
+//
+// 所有函数的第一个参数都是用户授权的访问令牌；分页的函数中count为每页的数量，page为页码（从1开始），
+// 为0时使用API的默认值。
+type FavoritesService struct {
+	weibo *Weibo
+}
+
+// 返回收藏相关的API
+func (weibo *Weibo) Favorites() *FavoritesService {
+	return &FavoritesService{weibo: weibo}
+}
+
+// 调用favorites，得到当前用户的收藏
+func (service *FavoritesService) List(token string, count int, page int) (*Favorites, error) {
+	var favorites Favorites
+	err := service.weibo.Call("favorites", "get", token, pageParams(count, page), &favorites)
+	return &favorites, err
+}
+
+// 抓取当前用户的全部收藏，按照收藏时间从新到旧排序
+//
+// 逐页抓取直到得到的收藏数达到total_number，或者遇到不满一页（包括空）的页；API没有返回total_number时只以后者为准。
+func (service *FavoritesService) ListAll(token string) ([]*Favorite, error) {
+	all := make([]*Favorite, 0)
+	for page := 1; ; page++ {
+		favorites, err := service.List(token, favoritesPerPage, page)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, favorites.Favorites...)
+		if len(favorites.Favorites) < favoritesPerPage {
+			return all, nil
+		}
+		if favorites.Total_Number > 0 && len(all) >= favorites.Total_Number {
+			return all, nil
+		}
+	}
+}
+
+// 调用favorites/ids，得到当前用户收藏的微博ID和标签
+func (service *FavoritesService) Ids(token string, count int, page int) (*FavoriteIds, error) {
+	var ids FavoriteIds
+	err := service.weibo.Call("favorites/ids", "get", token, pageParams(count, page), &ids)
+	return &ids, err
+}
+
+// 调用favorites/show，得到一条收藏
+func (service *FavoritesService) Show(token string, id ID) (*Favorite, error) {
+	var favorite Favorite
+	err := service.weibo.Call("favorites/show", "get", token, Params{"id": id}, &favorite)
+	return &favorite, err
+}
+
+// 调用favorites/by_tags，得到带有标签tagId的收藏
+func (service *FavoritesService) ByTags(token string, tagId ID, count int, page int) (*Favorites, error) {
+	params := pageParams(count, page)
+	params["tid"] = tagId
+	var favorites Favorites
+	err := service.weibo.Call("favorites/by_tags", "get", token, params, &favorites)
+	return &favorites, err
+}
+
+// 调用favorites/tags，得到当前用户的收藏标签
+func (service *FavoritesService) Tags(token string, count int, page int) (*FavoriteTags, error) {
+	var tags FavoriteTags
+	err := service.weibo.Call("favorites/tags", "get", token, pageParams(count, page), &tags)
+	return &tags, err
+}
+
+// 调用favorites/create，收藏一条微博
+func (service *FavoritesService) Create(token string, id ID) (*Favorite, error) {
+	var favorite Favorite
+	err := service.weibo.Call("favorites/create", "post", token, Params{"id": id}, &favorite)
+	return &favorite, err
+}
+
+// 调用favorites/destroy，取消收藏一条微博
+func (service *FavoritesService) Destroy(token string, id ID) (*Favorite, error) {
+	var favorite Favorite
+	err := service.weibo.Call("favorites/destroy", "post", token, Params{"id": id}, &favorite)
+	return &favorite, err
+}
+
+// 调用favorites/destroy_batch，取消收藏多条微博
+//
+// 超过MaxFavoritesPerBatch条时自动分成多次请求，出错时已经发出的请求不会撤销。
+func (service *FavoritesService) DestroyBatch(token string, ids []ID) error {
+	return service.batch("favorites/destroy_batch", token, ids)
+}
+
+// 调用favorites/tags/update，设置一条收藏的标签，tags最多两个
+func (service *FavoritesService) UpdateTags(token string, id ID, tags []string) (*Favorite, error) {
+	var favorite Favorite
+	params := Params{"id": id, "tags": strings.Join(tags, ",")}
+	err := service.weibo.Call("favorites/tags/update", "post", token, params, &favorite)
+	return &favorite, err
+}
+
+// 调用favorites/tags/update_batch，将标签tagId改名为tag，所有带有该标签的收藏随之更新
+func (service *FavoritesService) RenameTag(token string, tagId ID, tag string) (*FavoriteTag, error) {
+	var result FavoriteTag
+	params := Params{"tid": tagId, "tag": tag}
+	err := service.weibo.Call("favorites/tags/update_batch", "post", token, params, &result)
+	return &result, err
+}
+
+// 调用favorites/tags/destroy_batch，删除标签，所有带有这些标签的收藏随之更新
+//
+// 超过MaxFavoritesPerBatch个时自动分成多次请求，出错时已经发出的请求不会撤销。
+func (service *FavoritesService) DestroyTags(token string, tagIds []ID) error {
+	return service.batch("favorites/tags/destroy_batch", token, tagIds)
+}
+
+// 以ids参数分批调用批量删除的API
+func (service *FavoritesService) batch(method string, token string, ids []ID) error {
+	for start := 0; start < len(ids); start += MaxFavoritesPerBatch {
+		end := start + MaxFavoritesPerBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		// result有时为布尔值，有时为字符串"true"
+		var result struct {
+			Result interface{} `json:"result"`
+		}
+		if err := service.weibo.Call(method, "post", token, Params{"ids": joinIDs(ids[start:end])}, &result); err != nil {
+			return err
+		}
+		if fmt.Sprint(result.Result) != "true" {
+			return &ErrorString{method + "返回失败"}
+		}
+	}
+	return nil
+}
+
+// 生成分页参数，值为0的参数不发送
+func pageParams(count int, page int) Params {
+	params := Params{}
+	if count > 0 {
+		params["count"] = count
+	}
+	if page > 0 {
+		params["page"] = page
+	}
+	return params
+}
+
+// 将多个ID连接成API接受的逗号分隔的形式
+func joinIDs(ids []ID) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strings.Join(strs, ",")
+}
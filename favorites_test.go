@@ -0,0 +1,105 @@
+package gobo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/huichen/gobo"
+	"github.com/huichen/gobo/gobotest"
+)
+
+// 在模拟服务器上收藏超过一页（50条）的微博，ListAll应当得到全部收藏
+func TestFavoritesListAll(t *testing.T) {
+	server := gobotest.NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	aliceToken := server.IssueToken(alice)
+	bobToken := server.IssueToken(server.AddUser("bob"))
+
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+	const numFavorites = 120
+	ids := make([]gobo.ID, numFavorites)
+	for i := range ids {
+		var status gobo.Status
+		params := gobo.Params{"status": fmt.Sprintf("第%d条微博", i)}
+		if err := weibo.Call("statuses/update", "post", aliceToken, params, &status); err != nil {
+			t.Fatalf("statuses/update出错：%v", err)
+		}
+		ids[i] = status.Id
+		if _, err := weibo.Favorites().Create(bobToken, status.Id); err != nil {
+			t.Fatalf("favorites/create出错：%v", err)
+		}
+	}
+	_, err := weibo.Favorites().Create(bobToken, ids[0])
+	if weiboErr, ok := err.(gobo.WeiboError); !ok || weiboErr.Error_Code != gobotest.ErrorAlreadyFavorited {
+		t.Errorf("重复收藏返回 %v", err)
+	}
+
+	favorites, err := weibo.Favorites().ListAll(bobToken)
+	if err != nil {
+		t.Fatalf("ListAll出错：%v", err)
+	}
+	if len(favorites) != numFavorites {
+		t.Fatalf("ListAll得到%d条收藏，期望%d条", len(favorites), numFavorites)
+	}
+	for i, favorite := range favorites {
+		// 新收藏的在前
+		if expected := ids[numFavorites-1-i]; favorite.Status == nil || favorite.Status.Id != expected {
+			t.Fatalf("第%d条收藏为 %+v，期望微博%s", i, favorite.Status, expected)
+		}
+	}
+
+	if _, err := weibo.Favorites().Destroy(bobToken, ids[0]); err != nil {
+		t.Fatalf("favorites/destroy出错：%v", err)
+	}
+	if favorites, err := weibo.Favorites().ListAll(bobToken); err != nil || len(favorites) != numFavorites-1 {
+		t.Errorf("取消收藏后ListAll得到%d条收藏，错误为 %v", len(favorites), err)
+	}
+}
+
+// API没有返回total_number时，ListAll一直抓取到不满一页或者空的页为止
+func TestFavoritesListAllWithoutTotalNumber(t *testing.T) {
+	cases := []struct {
+		name  string
+		pages []int // 每页的收藏数
+	}{
+		{"最后一页不满", []int{50, 50, 7}},
+		{"最后一页为空", []int{50, 50, 0}},
+		{"没有收藏", []int{0}},
+	}
+	for _, c := range cases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			favorites := make([]*gobo.Favorite, 0)
+			if page >= 1 && page <= len(c.pages) {
+				for i := 0; i < c.pages[page-1]; i++ {
+					favorites = append(favorites, &gobo.Favorite{Status: &gobo.Status{Id: gobo.ID(page*1000 + i)}})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"favorites": favorites})
+		}))
+
+		var weibo gobo.Weibo
+		weibo.SetBaseURL(server.URL)
+		favorites, err := weibo.Favorites().ListAll("token")
+		server.Close()
+		if err != nil {
+			t.Errorf("%s：ListAll出错：%v", c.name, err)
+			continue
+		}
+		total := 0
+		for _, n := range c.pages {
+			total += n
+		}
+		if len(favorites) != total || requests != len(c.pages) {
+			t.Errorf("%s：请求了%d页，得到%d条收藏，期望%d页%d条", c.name, requests, len(favorites), len(c.pages), total)
+		}
+	}
+}
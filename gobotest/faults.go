@@ -0,0 +1,264 @@
+package gobotest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/huichen/gobo"
+)
+
+// 可以注入的故障类型
+const (
+	FaultLatency     = iota // 延迟之后正常发送请求
+	FaultReset              // 连接被重置，请求返回网络错误
+	FaultServerError        // 返回5xx状态码和HTML错误页面
+
+	// 正常发送请求，但响应内容在中途被截断。注意请求已经真实发送到服务器，
+	// 对于POST方法（比如statuses/update）服务器端的状态已经改变，调用者重试时会重复执行
+	FaultTruncatedJSON
+	FaultRateLimit    // 返回10023频率限制错误
+	FaultTokenExpired // 返回21327访问令牌过期错误
+)
+
+var faultNames = map[int]string{
+	FaultLatency:       "latency",
+	FaultReset:         "reset",
+	FaultServerError:   "server_error",
+	FaultTruncatedJSON: "truncated_json",
+	FaultRateLimit:     "rate_limit",
+	FaultTokenExpired:  "token_expired",
+}
+
+// Fault结构体定义了一个故障
+type Fault struct {
+	Type       int           // 故障类型，比如FaultLatency
+	Latency    time.Duration // FaultLatency的延迟，默认为1秒
+	StatusCode int           // FaultServerError的HTTP状态码，默认为502
+}
+
+// FaultRule结构体定义了对哪些请求注入什么故障
+//
+// 对匹配的请求，首先按顺序使用Script：第1个匹配的请求注入Script[0]，第2个注入Script[1]，依此类推，
+// nil表示该请求不注入故障；Script用完之后以Probability的概率注入Fault。
+type FaultRule struct {
+	Endpoint    string   // API方法名，比如 "statuses/update"，以"/"结尾时匹配所有以其开头的API方法名，为空时匹配所有请求
+	Script      []*Fault // 按顺序注入的故障
+	Fault       Fault    // 按概率注入的故障
+	Probability float64  // 注入Fault的概率，0到1之间
+
+	matched int // 已经匹配的请求数
+}
+
+// InjectedFault记录一次注入的故障
+type InjectedFault struct {
+	Time     time.Time
+	Method   string
+	Endpoint string
+	Fault    string // 故障类型的名称，比如 "rate_limit"
+}
+
+// FaultTransport结构体是按照规则注入故障的http.RoundTripper，用于测试程序在微博API不稳定时的表现
+//
+// 每个请求按顺序检查规则，使用第一条匹配API方法名的规则；没有注入故障的请求通过Transport正常发送。
+//
+// 用法
+//
+//	faults := gobotest.NewFaultTransport(nil, 1,
+//		&gobotest.FaultRule{Endpoint: "statuses/update", Script: []*gobotest.Fault{{Type: gobotest.FaultRateLimit}, nil}},
+//		&gobotest.FaultRule{Fault: gobotest.Fault{Type: gobotest.FaultReset}, Probability: 0.1})
+//	weibo.SetHTTPClient(&http.Client{Transport: faults})
+//	...
+//	t.Log(faults.Report())
+type FaultTransport struct {
+	// 发送请求使用的RoundTripper，为nil时使用http.DefaultTransport
+	Transport http.RoundTripper
+
+	// 记录每次注入的故障，为nil时不记录
+	Logger *slog.Logger
+
+	rules    []*FaultRule
+	mutex    sync.Mutex
+	random   *rand.Rand
+	injected []InjectedFault
+}
+
+// 生成FaultTransport，seed为按概率注入故障时使用的随机数种子，相同的种子和请求序列注入相同的故障
+func NewFaultTransport(transport http.RoundTripper, seed int64, rules ...*FaultRule) *FaultTransport {
+	return &FaultTransport{
+		Transport: transport,
+		rules:     rules,
+		random:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// 实现http.RoundTripper接口
+func (ft *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointOf(req.URL)
+	fault := ft.choose(endpoint)
+	transport := ft.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if fault == nil {
+		return transport.RoundTrip(req)
+	}
+	ft.record(req.Method, endpoint, fault)
+
+	switch fault.Type {
+	case FaultLatency:
+		latency := fault.Latency
+		if latency == 0 {
+			latency = time.Second
+		}
+		if err := sleep(req.Context(), latency); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+		return transport.RoundTrip(req)
+	case FaultTruncatedJSON:
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = body[:len(body)/2]
+		resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+
+	closeBody(req)
+	switch fault.Type {
+	case FaultReset:
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case FaultServerError:
+		status := fault.StatusCode
+		if status == 0 {
+			status = http.StatusBadGateway
+		}
+		page := fmt.Sprintf("<html>\r\n<head><title>%d %s</title></head>\r\n<body>\r\n<center><h1>%d %s</h1></center>\r\n</body>\r\n</html>\r\n",
+			status, http.StatusText(status), status, http.StatusText(status))
+		return newResponse(req, status, "text/html", []byte(page)), nil
+	case FaultRateLimit:
+		apiErr := &apiError{http.StatusForbidden, ErrorRateLimit, "User requests out of rate limit!"}
+		return newResponse(req, apiErr.status, "application/json;charset=UTF-8", apiErr.body(req.URL.Path)), nil
+	case FaultTokenExpired:
+		apiErr := &apiError{http.StatusUnauthorized, ErrorExpiredToken, "expired_token"}
+		return newResponse(req, apiErr.status, "application/json;charset=UTF-8", apiErr.body(req.URL.Path)), nil
+	}
+	return nil, &gobo.ErrorString{S: fmt.Sprintf("gobotest: 未知的故障类型 %d", fault.Type)}
+}
+
+// 按照规则决定是否注入故障，不注入时返回nil
+func (ft *FaultTransport) choose(endpoint string) *Fault {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	for _, rule := range ft.rules {
+		if !matchEndpoint(rule.Endpoint, endpoint) {
+			continue
+		}
+		index := rule.matched
+		rule.matched++
+		if index < len(rule.Script) {
+			return rule.Script[index]
+		}
+		if rule.Probability > 0 && ft.random.Float64() < rule.Probability {
+			fault := rule.Fault
+			return &fault
+		}
+		return nil
+	}
+	return nil
+}
+
+func matchEndpoint(pattern string, endpoint string) bool {
+	if pattern == "" || pattern == endpoint {
+		return true
+	}
+	return strings.HasSuffix(pattern, "/") && strings.HasPrefix(endpoint, pattern)
+}
+
+func (ft *FaultTransport) record(method string, endpoint string, fault *Fault) {
+	name, ok := faultNames[fault.Type]
+	if !ok {
+		name = fmt.Sprintf("unknown(%d)", fault.Type)
+	}
+	ft.mutex.Lock()
+	ft.injected = append(ft.injected, InjectedFault{Time: time.Now(), Method: method, Endpoint: endpoint, Fault: name})
+	ft.mutex.Unlock()
+	if ft.Logger != nil {
+		ft.Logger.Info("gobotest注入故障", "method", method, "endpoint", endpoint, "fault", name)
+	}
+}
+
+// 返回注入过的所有故障，按照注入的先后排序
+func (ft *FaultTransport) Injected() []InjectedFault {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	return append([]InjectedFault(nil), ft.injected...)
+}
+
+// 返回注入故障的统计报告，每行为一个API方法名和故障类型的注入次数，比如
+//
+//	statuses/update rate_limit 2
+//	users/show reset 1
+func (ft *FaultTransport) Report() string {
+	counts := make(map[string]int)
+	for _, fault := range ft.Injected() {
+		counts[fault.Endpoint+" "+fault.Fault]++
+	}
+	lines := make([]string, 0, len(counts))
+	for key, count := range counts {
+		lines = append(lines, fmt.Sprintf("%s %d", key, count))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RoundTripper即使不发送请求也必须关闭请求内容
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func newResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          ioutil.NopCloser(strings.NewReader(string(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,143 @@
+package gobotest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/huichen/gobo"
+)
+
+// Script中的故障按照请求的顺序注入，用完之后不再注入；规则只对匹配的API方法生效
+func TestFaultTransportScript(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	token := server.IssueToken(alice)
+
+	faults := NewFaultTransport(nil, 1,
+		&FaultRule{Endpoint: "statuses/update", Script: []*Fault{
+			{Type: FaultRateLimit},
+			nil,
+			{Type: FaultTokenExpired},
+			{Type: FaultReset},
+			{Type: FaultServerError, StatusCode: http.StatusServiceUnavailable},
+			{Type: FaultTruncatedJSON},
+		}},
+		&FaultRule{Endpoint: "statuses/", Script: []*Fault{{Type: FaultLatency, Latency: 50 * time.Millisecond}}})
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.SetHTTPClient(&http.Client{Transport: faults})
+
+	update := func(i int) error {
+		return weibo.Call("statuses/update", "post", token, gobo.Params{"status": fmt.Sprintf("第%d条", i)}, &gobo.Status{})
+	}
+	expectWeiboError(t, "第1个请求", update(1), ErrorRateLimit)
+	if err := update(2); err != nil {
+		t.Errorf("第2个请求不应当注入故障：%v", err)
+	}
+	expectWeiboError(t, "第3个请求", update(3), ErrorExpiredToken)
+	if err := update(4); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("第4个请求返回 %v，期望连接被重置", err)
+	}
+	if err := update(5); err == nil {
+		t.Error("第5个请求返回HTML错误页面，应当返回错误")
+	} else if _, ok := err.(gobo.WeiboError); ok {
+		t.Errorf("第5个请求返回微博错误 %v", err)
+	}
+	if err := update(6); err == nil {
+		t.Error("第6个请求的响应被截断，应当返回错误")
+	}
+	if err := update(7); err != nil {
+		t.Errorf("Script用完之后不应当注入故障：%v", err)
+	}
+
+	// statuses/update之外的statuses/方法使用第二条规则；截断的请求已经在服务器上发布了微博
+	start := time.Now()
+	var timeline gobo.Statuses
+	if err := weibo.Call("statuses/user_timeline", "get", token, nil, &timeline); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("没有注入延迟")
+	}
+	texts := make([]string, 0)
+	for _, status := range timeline.Statuses {
+		texts = append(texts, status.Text)
+	}
+	if fmt.Sprint(texts) != "[第7条 第6条 第2条]" {
+		t.Errorf("服务器上的微博为 %v", texts)
+	}
+	if err := weibo.Call("users/show", "get", token, gobo.Params{"uid": alice}, &gobo.User{}); err != nil {
+		t.Errorf("不匹配规则的请求不应当注入故障：%v", err)
+	}
+
+	injected := make([]string, 0)
+	for _, fault := range faults.Injected() {
+		injected = append(injected, fault.Method+" "+fault.Endpoint+" "+fault.Fault)
+	}
+	expected := []string{
+		"POST statuses/update rate_limit",
+		"POST statuses/update token_expired",
+		"POST statuses/update reset",
+		"POST statuses/update server_error",
+		"POST statuses/update truncated_json",
+		"GET statuses/user_timeline latency",
+	}
+	if strings.Join(injected, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("注入的故障为\n%s\n期望\n%s", strings.Join(injected, "\n"), strings.Join(expected, "\n"))
+	}
+	report := "statuses/update rate_limit 1\nstatuses/update reset 1\nstatuses/update server_error 1\n" +
+		"statuses/update token_expired 1\nstatuses/update truncated_json 1\nstatuses/user_timeline latency 1"
+	if faults.Report() != report {
+		t.Errorf("Report()为\n%s\n期望\n%s", faults.Report(), report)
+	}
+}
+
+// 相同的种子和请求序列按概率注入相同的故障
+func TestFaultTransportSeed(t *testing.T) {
+	upstream := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return newResponse(req, http.StatusOK, "application/json", []byte(`{}`)), nil
+	})
+	run := func(seed int64) string {
+		faults := NewFaultTransport(upstream, seed,
+			&FaultRule{Endpoint: "users/show", Fault: Fault{Type: FaultReset}, Probability: 0.3})
+		client := &http.Client{Transport: faults}
+		var pattern bytes.Buffer
+		for i := 0; i < 200; i++ {
+			endpoint := "users/show"
+			if i%2 == 1 {
+				endpoint = "statuses/show"
+			}
+			resp, err := client.Get("http://api.weibo.test/2/" + endpoint + ".json")
+			switch {
+			case err != nil && endpoint != "users/show":
+				t.Fatalf("%s不匹配规则，不应当注入故障：%v", endpoint, err)
+			case err != nil:
+				pattern.WriteByte('x')
+			default:
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				pattern.WriteByte('.')
+			}
+		}
+		return pattern.String()
+	}
+
+	first := run(42)
+	if second := run(42); second != first {
+		t.Errorf("相同的种子注入了不同的故障：\n%s\n%s", first, second)
+	}
+	if other := run(43); other == first {
+		t.Error("不同的种子注入了相同的故障")
+	}
+	if n := strings.Count(first, "x"); n < 10 || n > 60 {
+		t.Errorf("100个匹配的请求中注入了%d个故障，期望约30个", n)
+	}
+}
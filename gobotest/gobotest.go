@@ -0,0 +1,95 @@
+// gobotest包提供了在不访问真实微博API的情况下测试gobo程序的工具
+package gobotest
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/huichen/gobo"
+)
+
+// 录制时隐藏、匹配时忽略的参数
+var secretParams = map[string]bool{
+	"access_token":  true,
+	"client_secret": true,
+	"code":          true,
+	"refresh_token": true,
+}
+
+// 从请求URL中得到API方法名，比如 "/2/users/show.json" 为 "users/show"，"/oauth2/access_token" 为 "oauth2/access_token"
+func endpointOf(u *url.URL) string {
+	endpoint := strings.TrimPrefix(u.Path, "/")
+	endpoint = strings.TrimPrefix(endpoint, gobo.ApiVersion+"/")
+	return strings.TrimSuffix(endpoint, gobo.ApiNamePostfix)
+}
+
+// 从请求的URL和body中读取全部参数（query string、form和multipart中的非文件字段）
+//
+// body为请求的内容，可以为nil。keepFiles为true时返回的files包含multipart中上传的文件，键为字段名；
+// 为false时上传的文件被跳过而不读入内存。
+func readParams(req *http.Request, body io.Reader, keepFiles bool) (params url.Values, files map[string][]byte, err error) {
+	params = url.Values{}
+	for k, v := range req.URL.Query() {
+		params[k] = append(params[k], v...)
+	}
+	files = make(map[string][]byte)
+	if body == nil {
+		return params, files, nil
+	}
+
+	mediaType, mediaParams, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range form {
+			params[k] = append(params[k], v...)
+		}
+	case "multipart/form-data":
+		reader := multipart.NewReader(body, mediaParams["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FileName() != "" && !keepFiles {
+				if _, err := io.Copy(ioutil.Discard, part); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			data, err := ioutil.ReadAll(part)
+			if err != nil {
+				return nil, nil, err
+			}
+			if part.FileName() != "" {
+				files[part.FormName()] = data
+			} else {
+				params.Add(part.FormName(), string(data))
+			}
+		}
+	}
+	return params, files, nil
+}
+
+// 返回去掉了访问令牌等秘密参数并排序编码后的参数，用于匹配和保存
+func canonicalParams(params url.Values) string {
+	values := url.Values{}
+	for k, v := range params {
+		if !secretParams[k] {
+			values[k] = v
+		}
+	}
+	return values.Encode()
+}
@@ -0,0 +1,300 @@
+package gobotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/huichen/gobo"
+)
+
+// Recorder的工作模式
+const (
+	// 回放录制文件中的响应，找不到匹配的录制时见Recorder.Strict
+	ModeReplay = iota
+
+	// 忽略已有的录制文件，发送真实请求并录制
+	ModeRecord
+)
+
+// 录制时保存的响应头，其它响应头（Set-Cookie、Date以及服务器节点、请求ID等可以识别请求的头）不被保存
+var recordedHeaders = []string{"Content-Type", "Cache-Control", "Expires", "Last-Modified", "Etag", "Retry-After"}
+
+// Recorder结构体是录制和回放API请求的http.RoundTripper
+//
+// 录制时请求和响应被保存在一个JSON文件（cassette）中，访问令牌、client_secret、授权码和刷新令牌
+// 从请求参数和响应内容中隐藏，请求头、上传的图片内容和recordedHeaders之外的响应头不被保存。回放时请求按照HTTP方法、API方法名和
+// 去掉秘密参数后排序的参数匹配录制的请求；多个录制匹配同一个请求时按照录制的顺序依次使用，
+// 用完之后重复使用最后一个。
+//
+// 用法
+//
+//	rec, err := gobotest.NewRecorder("testdata/users_show.json", gobotest.ModeReplay)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Save()
+//	rec.Strict = true
+//	weibo.SetHTTPClient(rec.Client())
+//
+// 第一次运行时使用ModeRecord和真实的访问令牌录制，之后即可在没有网络的环境下回放。
+type Recorder struct {
+	// 录制时发送真实请求使用的RoundTripper，为nil时使用http.DefaultTransport
+	Transport http.RoundTripper
+
+	// 严格模式：回放时找不到匹配的录制则返回错误。非严格模式下发送真实请求并追加录制
+	Strict bool
+
+	path      string
+	mode      int
+	mutex     sync.Mutex
+	cassette  cassette
+	used      []bool // 和cassette.Interactions一一对应，表示该录制是否已经回放过
+	modified  bool
+	unmatched []string
+}
+
+// 录制文件的格式
+type cassette struct {
+	Interactions []*interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Params   string `json:"params"` // 去掉秘密参数后排序的query string
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// 生成使用录制文件path的Recorder
+//
+// mode为ModeReplay时读取path，文件不存在时视为空的录制；mode为ModeRecord时不读取。
+func NewRecorder(path string, mode int) (*Recorder, error) {
+	if mode != ModeReplay && mode != ModeRecord {
+		return nil, &gobo.ErrorString{S: "未知的Recorder模式"}
+	}
+	rec := &Recorder{path: path, mode: mode}
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &rec.cassette); err != nil {
+				return nil, err
+			}
+		}
+		rec.used = make([]bool, len(rec.cassette.Interactions))
+	}
+	return rec, nil
+}
+
+// 返回使用该Recorder的http.Client，见Weibo.SetHTTPClient和Authenticator.SetHTTPClient
+func (rec *Recorder) Client() *http.Client {
+	return &http.Client{Transport: rec}
+}
+
+// 实现http.RoundTripper接口
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, outgoing, err := requestBody(req)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	params, _, err := readParams(req, body, false)
+	if body != nil {
+		body.Close()
+	}
+	if err != nil {
+		closeBody(outgoing)
+		return nil, err
+	}
+	key := recordedRequest{
+		Method:   req.Method,
+		Endpoint: endpointOf(req.URL),
+		Params:   canonicalParams(params),
+	}
+
+	if rec.mode == ModeReplay {
+		if resp, ok := rec.replay(key, req); ok {
+			closeBody(outgoing)
+			return resp, nil
+		}
+		if rec.Strict {
+			closeBody(outgoing)
+			rec.mutex.Lock()
+			rec.unmatched = append(rec.unmatched, fmt.Sprintf("%s %s?%s", key.Method, key.Endpoint, key.Params))
+			rec.mutex.Unlock()
+			return nil, &gobo.ErrorString{S: fmt.Sprintf("gobotest: 没有匹配的录制：%s %s?%s", key.Method, key.Endpoint, key.Params)}
+		}
+	}
+	return rec.record(key, outgoing)
+}
+
+// 返回用于读取参数的请求内容，以及之后真正发送的请求
+//
+// RoundTripper不能修改调用者的req。req.GetBody不为nil时通过它得到内容的副本，发送原来的req；
+// 否则（比如gobo通过io.Pipe流式发送的图片上传）将内容暂存到临时文件，发送使用该文件作为内容的req的副本，
+// 因此上传的图片不会被读入内存。没有内容时返回的body为nil。
+func requestBody(req *http.Request) (body io.ReadCloser, outgoing *http.Request, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		return body, req, nil
+	}
+
+	file, err := ioutil.TempFile("", "gobotest-body-")
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = io.Copy(file, req.Body)
+	req.Body.Close()
+	if err == nil {
+		body, err = os.Open(file.Name())
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, nil, err
+	}
+	outgoing = req.Clone(req.Context())
+	outgoing.Body = &spooledBody{file}
+	return body, outgoing, nil
+}
+
+// 暂存在临时文件中的请求内容，关闭时删除临时文件
+type spooledBody struct {
+	*os.File
+}
+
+func (body *spooledBody) Close() error {
+	err := body.File.Close()
+	os.Remove(body.File.Name())
+	return err
+}
+
+// 找到匹配的录制并生成响应
+func (rec *Recorder) replay(key recordedRequest, req *http.Request) (*http.Response, bool) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	last := -1
+	for i, in := range rec.cassette.Interactions {
+		if in.Request != key {
+			continue
+		}
+		if !rec.used[i] {
+			rec.used[i] = true
+			return in.Response.toHTTP(req), true
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil, false
+	}
+	return rec.cassette.Interactions[last].Response.toHTTP(req), true
+}
+
+// 发送真实请求并录制响应
+func (rec *Recorder) record(key recordedRequest, req *http.Request) (*http.Response, error) {
+	transport := rec.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := http.Header{}
+	for _, name := range recordedHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	rec.mutex.Lock()
+	rec.cassette.Interactions = append(rec.cassette.Interactions, &interaction{
+		Request: key,
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       gobo.RedactBody(body),
+		},
+	})
+	rec.used = append(rec.used, true)
+	rec.modified = true
+	rec.mutex.Unlock()
+	return resp, nil
+}
+
+func (r recordedResponse) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// 返回严格模式下没有匹配的请求，格式为 "GET users/show?uid=123"
+func (rec *Recorder) Unmatched() []string {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append([]string(nil), rec.unmatched...)
+}
+
+// 当录制了新的请求时将录制写入文件，目录不存在时创建
+func (rec *Recorder) Save() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if !rec.modified {
+		return nil
+	}
+	data, err := json.MarshalIndent(&rec.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(rec.path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	rec.modified = false
+	return nil
+}
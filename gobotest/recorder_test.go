@@ -0,0 +1,178 @@
+package gobotest
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huichen/gobo"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 录制授权、普通API调用和图片上传，检查访问令牌、client_secret、授权码以及请求头和响应头中的秘密
+// 都没有写入录制文件，然后在严格模式下回放
+func TestRecorderHidesSecrets(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	server.SetAuthorizer(alice)
+
+	const (
+		clientSecret = "client-secret-0123456789"
+		cookie       = "SUB=cookie-secret-0123456789"
+		logUid       = "log-uid-secret-0123456789"
+		authHeader   = "OAuth2 header-secret-0123456789"
+	)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟微博服务器在响应头中返回的会话和请求信息
+	rec.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			resp.Header.Add("Set-Cookie", cookie)
+			resp.Header.Set("X-Log-Uid", logUid)
+		}
+		return resp, err
+	})
+
+	var auth gobo.Authenticator
+	auth.SetBaseURL(server.URL)
+	auth.SetHTTPClient(rec.Client())
+	if err := auth.Init("http://localhost/callback", "appkey", clientSecret); err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, &auth)
+	token, err := auth.AccessToken(code)
+	if err != nil {
+		t.Fatalf("oauth2/access_token出错：%v", err)
+	}
+
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.SetHTTPClient(rec.Client())
+	var user gobo.User
+	if err := weibo.Call("users/show", "get", token.Access_Token, gobo.Params{"uid": alice}, &user); err != nil {
+		t.Fatalf("users/show出错：%v", err)
+	}
+	var status gobo.Status
+	err = weibo.Upload(token.Access_Token, gobo.Params{"status": "带图片"}, bytes.NewReader(testPNG(t)), "png", &status)
+	if err != nil {
+		t.Fatalf("statuses/upload出错：%v", err)
+	}
+	if status.Original_Pic == "" {
+		t.Errorf("statuses/upload返回 %+v", status)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/2/users/show.json?uid="+alice.String()+"&access_token="+token.Access_Token, nil)
+	req.Header.Set("Authorization", authHeader)
+	req.Header.Set("Cookie", cookie)
+	resp, err := rec.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, secret := range map[string]string{
+		"访问令牌":          token.Access_Token,
+		"client_secret": clientSecret,
+		"授权码":           code,
+		"Cookie":        cookie,
+		"响应头":           logUid,
+		"Authorization": authHeader,
+	} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("录制文件中出现了%s %q", name, secret)
+		}
+	}
+
+	// 回放时不需要访问令牌和服务器
+	replay, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.Strict = true
+	weibo.SetHTTPClient(replay.Client())
+	var replayed gobo.User
+	if err := weibo.Call("users/show", "get", "other-token", gobo.Params{"uid": alice}, &replayed); err != nil {
+		t.Fatalf("回放users/show出错：%v", err)
+	}
+	if replayed.Id != user.Id || replayed.Screen_Name != user.Screen_Name {
+		t.Errorf("回放users/show返回 %+v，期望 %+v", replayed, user)
+	}
+	if unmatched := replay.Unmatched(); len(unmatched) > 0 {
+		t.Errorf("没有匹配的请求：%v", unmatched)
+	}
+}
+
+// 不能重复读取的请求内容（比如流式上传）被完整发送，并且调用者的请求没有被修改
+func TestRecorderStreamedBody(t *testing.T) {
+	const body = "status=%E4%BD%A0%E5%A5%BD&access_token=secret"
+	var received string
+	rec, err := NewRecorder(filepath.Join(t.TempDir(), "cassette.json"), ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		received = string(data)
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	original := ioutil.NopCloser(strings.NewReader(body))
+	req, _ := http.NewRequest("POST", "https://api.weibo.com/2/statuses/update.json", original)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.GetBody = nil
+	resp, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if received != body {
+		t.Errorf("发送的请求内容为 %q，期望 %q", received, body)
+	}
+	if req.Body != original {
+		t.Error("RoundTrip修改了调用者请求的Body")
+	}
+	if got := rec.cassette.Interactions[0].Request.Params; got != "status=%E4%BD%A0%E5%A5%BD" {
+		t.Errorf("录制的参数为 %q", got)
+	}
+
+	// 暂存请求内容的临时文件已经被删除
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "gobotest-body-*"))
+	if len(matches) > 0 {
+		t.Errorf("临时文件没有被删除：%v", matches)
+	}
+}
+
+func testPNG(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
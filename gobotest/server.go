@@ -0,0 +1,995 @@
+package gobotest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/huichen/gobo"
+	"github.com/huichen/gobo/text"
+)
+
+// 模拟服务器返回的微博错误代码
+const (
+	ErrorSystem           = 10001 // 系统错误
+	ErrorParamMissing     = 10016 // 缺失必选参数
+	ErrorAPINotFound      = 10020 // 接口不存在
+	ErrorHTTPMethod       = 10021 // 接口不支持该HTTP请求方式
+	ErrorRateLimit        = 10023 // 用户请求频次超过上限
+	ErrorUserNotExist     = 20003 // 用户不存在
+	ErrorTextTooLong      = 20012 // 正文超过字数限制
+	ErrorRepeatContent    = 20019 // 相同内容短时间内不能重复发送
+	ErrorStatusNotExist   = 20101 // 微博不存在
+	ErrorNotYourStatus    = 20102 // 不是自己的微博
+	ErrorNotYourComment   = 20204 // 不是自己的评论
+	ErrorCommentNotExist  = 20206 // 评论不存在
+	ErrorFollowSelf       = 20504 // 不能关注自己
+	ErrorAlreadyFollowed  = 20506 // 已经关注
+	ErrorNotFollowed      = 20522 // 尚未关注
+	ErrorAlreadyFavorited = 20704 // 已经收藏
+	ErrorNotFavorited     = 20705 // 尚未收藏
+	ErrorAuthFailed       = 21301 // 认证失败，比如缺少访问令牌
+	ErrorRedirectMismatch = 21322 // 重定向地址不匹配
+	ErrorIllegalRequest   = 21323 // 请求不合法，比如缺少client_id
+	ErrorInvalidGrant     = 21325 // 授权码无效
+	ErrorExpiredToken     = 21327 // 访问令牌过期
+	ErrorInvalidToken     = 21332 // 访问令牌无效
+)
+
+// 模拟服务器发放的访问令牌的有效期，单位为秒
+const TokenExpiresIn = 157679999
+
+// Server结构体是在进程内运行的模拟微博API服务器，所有数据保存在内存中
+//
+// 支持的API包括
+//
+//	oauth2/authorize, oauth2/access_token, oauth2/get_token_info, oauth2/revokeoauth2
+//	statuses/update, statuses/upload, statuses/show, statuses/destroy
+//	statuses/user_timeline, statuses/home_timeline, statuses/friends_timeline, statuses/public_timeline
+//	comments/create, comments/reply, comments/show, comments/destroy
+//	users/show
+//	friendships/create, friendships/destroy, friendships/friends, friendships/followers
+//	favorites, favorites/ids, favorites/show, favorites/create, favorites/destroy
+//
+// 无效、过期或者缺少访问令牌，重复发布相同内容，超过频率限制等情况返回和微博相同的错误代码。
+//
+// 用法
+//
+//	server := gobotest.NewServer()
+//	defer server.Close()
+//	uid := server.AddUser("测试用户")
+//	token := server.IssueToken(uid)
+//	weibo := gobo.Weibo{}
+//	weibo.SetBaseURL(server.URL)
+type Server struct {
+	*httptest.Server
+
+	mutex      sync.Mutex
+	rateLimit  int
+	nextUserID int64
+	nextID     int64
+	users      map[gobo.ID]*fakeUser
+	userOrder  []gobo.ID
+	tokens     map[string]*fakeToken
+	codes      map[string]gobo.ID // 授权码对应的用户
+	authorizer gobo.ID            // oauth2/authorize时同意授权的用户
+	statuses   map[gobo.ID]*fakeStatus
+	timeline   []gobo.ID // 所有微博，按照发布的先后排序
+	comments   map[gobo.ID]*fakeComment
+	following  map[gobo.ID]map[gobo.ID]bool // 关注者 -> 被关注者
+	favorites  map[gobo.ID][]*fakeFavorite  // 用户的收藏，按照收藏的先后排序
+	calls      map[string]int               // 每个访问令牌调用API的次数
+}
+
+type fakeUser struct {
+	id         gobo.ID
+	screenName string
+	createdAt  time.Time
+}
+
+type fakeToken struct {
+	uid       gobo.ID
+	appkey    string
+	createdAt time.Time
+	expired   bool
+}
+
+type fakeStatus struct {
+	id        gobo.ID
+	uid       gobo.ID
+	text      string
+	picId     string
+	createdAt time.Time
+	comments  []gobo.ID
+}
+
+type fakeFavorite struct {
+	statusId    gobo.ID
+	favoritedAt time.Time
+}
+
+type fakeComment struct {
+	id        gobo.ID
+	uid       gobo.ID
+	statusId  gobo.ID
+	replyTo   gobo.ID
+	text      string
+	createdAt time.Time
+}
+
+// apiError表示返回给客户端的微博错误
+type apiError struct {
+	status int
+	code   int64
+	msg    string
+}
+
+// 一次API请求的上下文
+type apiContext struct {
+	endpoint string
+	params   url.Values
+	files    map[string][]byte
+	uid      gobo.ID // 访问令牌对应的用户，oauth2接口中为0
+	token    string
+}
+
+type apiHandler struct {
+	method    string // "GET"或者"POST"
+	needToken bool
+	handle    func(s *Server, ctx *apiContext) (interface{}, *apiError)
+}
+
+var apiHandlers = map[string]apiHandler{
+	"oauth2/access_token":       {"POST", false, (*Server).accessToken},
+	"oauth2/get_token_info":     {"POST", false, (*Server).getTokenInfo},
+	"oauth2/revokeoauth2":       {"POST", false, (*Server).revokeOauth2},
+	"statuses/update":           {"POST", true, (*Server).updateStatus},
+	"statuses/upload":           {"POST", true, (*Server).updateStatus},
+	"statuses/show":             {"GET", true, (*Server).showStatus},
+	"statuses/destroy":          {"POST", true, (*Server).destroyStatus},
+	"statuses/user_timeline":    {"GET", true, (*Server).userTimeline},
+	"statuses/home_timeline":    {"GET", true, (*Server).homeTimeline},
+	"statuses/friends_timeline": {"GET", true, (*Server).homeTimeline},
+	"statuses/public_timeline":  {"GET", true, (*Server).publicTimeline},
+	"comments/create":           {"POST", true, (*Server).createComment},
+	"comments/reply":            {"POST", true, (*Server).createComment},
+	"comments/show":             {"GET", true, (*Server).showComments},
+	"comments/destroy":          {"POST", true, (*Server).destroyComment},
+	"users/show":                {"GET", true, (*Server).showUser},
+	"friendships/create":        {"POST", true, (*Server).createFriendship},
+	"friendships/destroy":       {"POST", true, (*Server).destroyFriendship},
+	"friendships/friends":       {"GET", true, (*Server).friends},
+	"friendships/followers":     {"GET", true, (*Server).followers},
+	"favorites":                 {"GET", true, (*Server).listFavorites},
+	"favorites/ids":             {"GET", true, (*Server).listFavorites},
+	"favorites/show":            {"GET", true, (*Server).showFavorite},
+	"favorites/create":          {"POST", true, (*Server).createFavorite},
+	"favorites/destroy":         {"POST", true, (*Server).destroyFavorite},
+}
+
+// 启动模拟服务器，使用完毕后应当调用Close
+//
+// 服务器的地址为Server.URL，通过Weibo.SetBaseURL和Authenticator.SetBaseURL使用。
+func NewServer() *Server {
+	s := &Server{
+		nextUserID: 1000000000,
+		nextID:     3500000000000000,
+		users:      make(map[gobo.ID]*fakeUser),
+		tokens:     make(map[string]*fakeToken),
+		codes:      make(map[string]gobo.ID),
+		statuses:   make(map[gobo.ID]*fakeStatus),
+		comments:   make(map[gobo.ID]*fakeComment),
+		following:  make(map[gobo.ID]map[gobo.ID]bool),
+		favorites:  make(map[gobo.ID][]*fakeFavorite),
+		calls:      make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// 添加一个用户，返回用户的ID
+func (s *Server) AddUser(screenName string) gobo.ID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addUser(screenName)
+}
+
+func (s *Server) addUser(screenName string) gobo.ID {
+	s.nextUserID++
+	id := gobo.ID(s.nextUserID)
+	s.users[id] = &fakeUser{id: id, screenName: screenName, createdAt: time.Now()}
+	s.userOrder = append(s.userOrder, id)
+	return id
+}
+
+// 为用户uid生成一个有效的访问令牌
+//
+// uid必须是AddUser返回的用户，否则panic。
+func (s *Server) IssueToken(uid gobo.ID) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.users[uid]; !ok {
+		panic("gobotest: IssueToken的用户" + uid.String() + "不存在，请先调用AddUser")
+	}
+	return s.issueToken(uid, "")
+}
+
+func (s *Server) issueToken(uid gobo.ID, appkey string) string {
+	token := "2.00" + randomString(16)
+	s.tokens[token] = &fakeToken{uid: uid, appkey: appkey, createdAt: time.Now()}
+	return token
+}
+
+// 使访问令牌过期，之后使用该令牌的请求返回21327错误
+func (s *Server) ExpireToken(token string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if t, ok := s.tokens[token]; ok {
+		t.expired = true
+	}
+}
+
+// 设置oauth2/authorize时同意授权的用户，默认为第一个添加的用户（没有用户时自动添加一个）
+func (s *Server) SetAuthorizer(uid gobo.ID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.authorizer = uid
+}
+
+// 设置每个访问令牌最多调用API的次数，超过后返回10023错误，为0时不限制（默认）
+//
+// 调用该函数同时清零已经调用的次数。
+func (s *Server) SetRateLimit(limit int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rateLimit = limit
+	s.calls = make(map[string]int)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := endpointOf(r.URL)
+	if endpoint == "oauth2/authorize" {
+		s.authorize(w, r)
+		return
+	}
+
+	handler, ok := apiHandlers[endpoint]
+	if !ok {
+		writeError(w, r, &apiError{http.StatusNotFound, ErrorAPINotFound, "Request api not found!"})
+		return
+	}
+	if r.Method != handler.method {
+		writeError(w, r, &apiError{http.StatusBadRequest, ErrorHTTPMethod, "HTTP method is not suported for this request!"})
+		return
+	}
+	params, files, err := readParams(r, r.Body, true)
+	if err != nil {
+		writeError(w, r, &apiError{http.StatusBadRequest, ErrorSystem, err.Error()})
+		return
+	}
+	ctx := &apiContext{endpoint: endpoint, params: params, files: files, token: params.Get("access_token")}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if handler.needToken {
+		if apiErr := s.checkToken(ctx); apiErr != nil {
+			writeError(w, r, apiErr)
+			return
+		}
+	}
+	result, apiErr := handler.handle(s, ctx)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	json.NewEncoder(w).Encode(result)
+}
+
+// 检查访问令牌和频率限制，成功时设置ctx.uid
+func (s *Server) checkToken(ctx *apiContext) *apiError {
+	if ctx.token == "" {
+		return &apiError{http.StatusUnauthorized, ErrorAuthFailed, "auth faild!"}
+	}
+	t, ok := s.tokens[ctx.token]
+	if !ok {
+		return &apiError{http.StatusUnauthorized, ErrorInvalidToken, "invalid_access_token"}
+	}
+	if t.expired {
+		return &apiError{http.StatusUnauthorized, ErrorExpiredToken, "expired_token"}
+	}
+	s.calls[ctx.token]++
+	if s.rateLimit > 0 && s.calls[ctx.token] > s.rateLimit {
+		return &apiError{http.StatusForbidden, ErrorRateLimit, "User requests out of rate limit!"}
+	}
+	if _, apiErr := s.findUser(t.uid); apiErr != nil {
+		return apiErr
+	}
+	ctx.uid = t.uid
+	return nil
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, apiErr *apiError) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(apiErr.status)
+	w.Write(apiErr.body(r.URL.Path))
+}
+
+// 生成和微博相同格式的错误JSON，path为请求的路径
+func (apiErr *apiError) body(path string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"error":      apiErr.msg,
+		"error_code": apiErr.code,
+		"request":    path,
+	})
+	return append(data, '\n')
+}
+
+func missingParam(name string) *apiError {
+	return &apiError{http.StatusBadRequest, ErrorParamMissing, "miss required parameter (" + name + "), see doc for more info."}
+}
+
+func randomString(n int) string {
+	b := make([]byte, n/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) newID() gobo.ID {
+	s.nextID++
+	return gobo.ID(s.nextID)
+}
+
+// 读取ID类型的参数，参数不存在时返回0
+func idParam(ctx *apiContext, name string) gobo.ID {
+	id, err := gobo.ParseID(ctx.params.Get(name))
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func intParam(ctx *apiContext, name string, defaultValue int) int {
+	value, err := strconv.Atoi(ctx.params.Get(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// 找到ID为uid的用户，不存在时返回20003错误
+func (s *Server) findUser(uid gobo.ID) (*fakeUser, *apiError) {
+	if u, ok := s.users[uid]; ok {
+		return u, nil
+	}
+	return nil, &apiError{http.StatusBadRequest, ErrorUserNotExist, "User does not exists!"}
+}
+
+// 根据uid或者screen_name参数找到用户，两者都没有时返回当前用户
+func (s *Server) targetUser(ctx *apiContext) (*fakeUser, *apiError) {
+	if uid := idParam(ctx, "uid"); uid != 0 {
+		return s.findUser(uid)
+	}
+	if name := ctx.params.Get("screen_name"); name != "" {
+		for _, id := range s.userOrder {
+			if u := s.users[id]; u.screenName == name {
+				return u, nil
+			}
+		}
+		return nil, &apiError{http.StatusBadRequest, ErrorUserNotExist, "User does not exists!"}
+	}
+	return s.findUser(ctx.uid)
+}
+
+// 授权相关
+
+// oauth2/authorize：模拟用户立即同意授权，重定向到redirect_uri并带上授权码
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") == "" {
+		writeError(w, r, &apiError{http.StatusBadRequest, ErrorIllegalRequest, "client_id is missing"})
+		return
+	}
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" {
+		writeError(w, r, &apiError{http.StatusBadRequest, ErrorRedirectMismatch, "redirect_uri_mismatch"})
+		return
+	}
+
+	s.mutex.Lock()
+	uid := s.authorizer
+	if _, ok := s.users[uid]; !ok {
+		if len(s.userOrder) == 0 {
+			s.addUser("gobotest")
+		}
+		uid = s.userOrder[0]
+	}
+	code := randomString(32)
+	s.codes[code] = uid
+	s.mutex.Unlock()
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	redirectURI.RawQuery = values.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) accessToken(ctx *apiContext) (interface{}, *apiError) {
+	for _, name := range []string{"client_id", "client_secret", "grant_type", "code", "redirect_uri"} {
+		if ctx.params.Get(name) == "" {
+			return nil, missingParam(name)
+		}
+	}
+	if ctx.params.Get("grant_type") != "authorization_code" {
+		return nil, &apiError{http.StatusBadRequest, ErrorIllegalRequest, "unsupported_grant_type"}
+	}
+	uid, ok := s.codes[ctx.params.Get("code")]
+	if !ok {
+		return nil, &apiError{http.StatusBadRequest, ErrorInvalidGrant, "invalid_grant"}
+	}
+	// 授权码只能使用一次
+	delete(s.codes, ctx.params.Get("code"))
+	token := s.issueToken(uid, ctx.params.Get("client_id"))
+	return map[string]interface{}{
+		"access_token": token,
+		"remind_in":    strconv.Itoa(TokenExpiresIn),
+		"expires_in":   TokenExpiresIn,
+		"uid":          uid.String(),
+	}, nil
+}
+
+// 查找oauth2接口中access_token参数对应的令牌
+func (s *Server) oauthToken(ctx *apiContext) (*fakeToken, *apiError) {
+	if ctx.token == "" {
+		return nil, missingParam("access_token")
+	}
+	t, ok := s.tokens[ctx.token]
+	if !ok {
+		return nil, &apiError{http.StatusBadRequest, ErrorInvalidToken, "invalid_access_token"}
+	}
+	if t.expired {
+		return nil, &apiError{http.StatusBadRequest, ErrorExpiredToken, "expired_token"}
+	}
+	return t, nil
+}
+
+func (s *Server) getTokenInfo(ctx *apiContext) (interface{}, *apiError) {
+	t, apiErr := s.oauthToken(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	expireIn := TokenExpiresIn - int(time.Since(t.createdAt).Seconds())
+	return map[string]interface{}{
+		"uid":       t.uid,
+		"appkey":    t.appkey,
+		"scope":     "",
+		"create_at": t.createdAt.Unix(),
+		"expire_in": expireIn,
+	}, nil
+}
+
+func (s *Server) revokeOauth2(ctx *apiContext) (interface{}, *apiError) {
+	if _, apiErr := s.oauthToken(ctx); apiErr != nil {
+		return nil, apiErr
+	}
+	delete(s.tokens, ctx.token)
+	return map[string]interface{}{"result": "true"}, nil
+}
+
+// 微博相关
+
+func (s *Server) updateStatus(ctx *apiContext) (interface{}, *apiError) {
+	content := ctx.params.Get("status")
+	if content == "" {
+		return nil, missingParam("status")
+	}
+	if text.Length(content) > gobo.MaxStatusLength {
+		return nil, &apiError{http.StatusBadRequest, ErrorTextTooLong, "Text too long, please input text less than 140 characters!"}
+	}
+	for _, id := range s.timeline {
+		if st := s.statuses[id]; st.uid == ctx.uid && st.text == content {
+			return nil, &apiError{http.StatusBadRequest, ErrorRepeatContent, "repeat content!"}
+		}
+	}
+
+	st := &fakeStatus{id: s.newID(), uid: ctx.uid, text: content, createdAt: time.Now()}
+	if ctx.endpoint == gobo.UploadAPIName {
+		if _, ok := ctx.files["pic"]; !ok {
+			return nil, missingParam("pic")
+		}
+		st.picId = randomString(32)
+	}
+	s.statuses[st.id] = st
+	s.timeline = append(s.timeline, st.id)
+	return s.statusJSON(st)
+}
+
+func (s *Server) findStatus(ctx *apiContext) (*fakeStatus, *apiError) {
+	if ctx.params.Get("id") == "" {
+		return nil, missingParam("id")
+	}
+	st, ok := s.statuses[idParam(ctx, "id")]
+	if !ok {
+		return nil, &apiError{http.StatusBadRequest, ErrorStatusNotExist, "target weibo does not exist!"}
+	}
+	return st, nil
+}
+
+func (s *Server) showStatus(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return s.statusJSON(st)
+}
+
+func (s *Server) destroyStatus(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if st.uid != ctx.uid {
+		return nil, &apiError{http.StatusBadRequest, ErrorNotYourStatus, "not your own weibo!"}
+	}
+	result, apiErr := s.statusJSON(st)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	delete(s.statuses, st.id)
+	for i, id := range s.timeline {
+		if id == st.id {
+			s.timeline = append(s.timeline[:i], s.timeline[i+1:]...)
+			break
+		}
+	}
+	for _, cid := range st.comments {
+		delete(s.comments, cid)
+	}
+	for uid := range s.favorites {
+		s.removeFavorite(uid, st.id)
+	}
+	return result, nil
+}
+
+func (s *Server) userTimeline(ctx *apiContext) (interface{}, *apiError) {
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return s.timelineJSON(ctx, func(st *fakeStatus) bool { return st.uid == user.id })
+}
+
+func (s *Server) homeTimeline(ctx *apiContext) (interface{}, *apiError) {
+	following := s.following[ctx.uid]
+	return s.timelineJSON(ctx, func(st *fakeStatus) bool { return st.uid == ctx.uid || following[st.uid] })
+}
+
+func (s *Server) publicTimeline(ctx *apiContext) (interface{}, *apiError) {
+	return s.timelineJSON(ctx, func(st *fakeStatus) bool { return true })
+}
+
+// 按照since_id、max_id、count和page参数输出符合条件的微博，新的在前
+func (s *Server) timelineJSON(ctx *apiContext, match func(st *fakeStatus) bool) (map[string]interface{}, *apiError) {
+	sinceID := idParam(ctx, "since_id")
+	maxID := idParam(ctx, "max_id")
+	matched := make([]*fakeStatus, 0)
+	for i := len(s.timeline) - 1; i >= 0; i-- {
+		st := s.statuses[s.timeline[i]]
+		if match(st) && st.id > sinceID && (maxID == 0 || st.id <= maxID) {
+			matched = append(matched, st)
+		}
+	}
+	statuses := make([]interface{}, 0)
+	for _, i := range page(ctx, len(matched)) {
+		status, apiErr := s.statusJSON(matched[i])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		statuses = append(statuses, status)
+	}
+	return map[string]interface{}{
+		"statuses":        statuses,
+		"total_number":    len(matched),
+		"previous_cursor": 0,
+		"next_cursor":     0,
+	}, nil
+}
+
+// 返回count和page参数对应的下标
+func page(ctx *apiContext, total int) []int {
+	count := intParam(ctx, "count", 20)
+	if count > 200 {
+		count = 200
+	}
+	start := (intParam(ctx, "page", 1) - 1) * count
+	indexes := make([]int, 0)
+	for i := start; i < total && i < start+count; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// 评论相关
+
+func (s *Server) createComment(ctx *apiContext) (interface{}, *apiError) {
+	content := ctx.params.Get("comment")
+	if content == "" {
+		return nil, missingParam("comment")
+	}
+	if text.Length(content) > gobo.MaxCommentLength {
+		return nil, &apiError{http.StatusBadRequest, ErrorTextTooLong, "Text too long, please input text less than 140 characters!"}
+	}
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	c := &fakeComment{id: s.newID(), uid: ctx.uid, statusId: st.id, text: content, createdAt: time.Now()}
+	if ctx.endpoint == "comments/reply" {
+		if ctx.params.Get("cid") == "" {
+			return nil, missingParam("cid")
+		}
+		replyTo, ok := s.comments[idParam(ctx, "cid")]
+		if !ok || replyTo.statusId != st.id {
+			return nil, &apiError{http.StatusBadRequest, ErrorCommentNotExist, "target comment does not exist!"}
+		}
+		c.replyTo = replyTo.id
+		if ctx.params.Get("without_mention") != "1" {
+			replyToUser, apiErr := s.findUser(replyTo.uid)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			c.text = "回复@" + replyToUser.screenName + ":" + content
+		}
+	}
+	s.comments[c.id] = c
+	st.comments = append(st.comments, c.id)
+	return s.commentJSON(c, true)
+}
+
+func (s *Server) showComments(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	comments := make([]interface{}, 0)
+	for _, i := range page(ctx, len(st.comments)) {
+		// 新的评论在前
+		comment, apiErr := s.commentJSON(s.comments[st.comments[len(st.comments)-1-i]], true)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		comments = append(comments, comment)
+	}
+	return map[string]interface{}{
+		"comments":        comments,
+		"total_number":    len(st.comments),
+		"previous_cursor": 0,
+		"next_cursor":     0,
+	}, nil
+}
+
+func (s *Server) destroyComment(ctx *apiContext) (interface{}, *apiError) {
+	if ctx.params.Get("cid") == "" {
+		return nil, missingParam("cid")
+	}
+	c, ok := s.comments[idParam(ctx, "cid")]
+	if !ok {
+		return nil, &apiError{http.StatusBadRequest, ErrorCommentNotExist, "target comment does not exist!"}
+	}
+	st := s.statuses[c.statusId]
+	// 评论的作者和微博的作者都可以删除评论
+	if c.uid != ctx.uid && st.uid != ctx.uid {
+		return nil, &apiError{http.StatusBadRequest, ErrorNotYourComment, "not your own comment!"}
+	}
+	result, apiErr := s.commentJSON(c, true)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	delete(s.comments, c.id)
+	for i, id := range st.comments {
+		if id == c.id {
+			st.comments = append(st.comments[:i], st.comments[i+1:]...)
+			break
+		}
+	}
+	return result, nil
+}
+
+// 用户和关系相关
+
+func (s *Server) showUser(ctx *apiContext) (interface{}, *apiError) {
+	if ctx.params.Get("uid") == "" && ctx.params.Get("screen_name") == "" {
+		return nil, missingParam("uid")
+	}
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return s.userJSON(user, ctx.uid, true), nil
+}
+
+func (s *Server) createFriendship(ctx *apiContext) (interface{}, *apiError) {
+	if ctx.params.Get("uid") == "" && ctx.params.Get("screen_name") == "" {
+		return nil, missingParam("uid")
+	}
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if user.id == ctx.uid {
+		return nil, &apiError{http.StatusBadRequest, ErrorFollowSelf, "Can not follow yourself!"}
+	}
+	if s.following[ctx.uid][user.id] {
+		return nil, &apiError{http.StatusBadRequest, ErrorAlreadyFollowed, "Already followed!"}
+	}
+	if s.following[ctx.uid] == nil {
+		s.following[ctx.uid] = make(map[gobo.ID]bool)
+	}
+	s.following[ctx.uid][user.id] = true
+	return s.userJSON(user, ctx.uid, true), nil
+}
+
+func (s *Server) destroyFriendship(ctx *apiContext) (interface{}, *apiError) {
+	if ctx.params.Get("uid") == "" && ctx.params.Get("screen_name") == "" {
+		return nil, missingParam("uid")
+	}
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !s.following[ctx.uid][user.id] {
+		return nil, &apiError{http.StatusBadRequest, ErrorNotFollowed, "Not followed!"}
+	}
+	delete(s.following[ctx.uid], user.id)
+	return s.userJSON(user, ctx.uid, true), nil
+}
+
+func (s *Server) friends(ctx *apiContext) (interface{}, *apiError) {
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	ids := make([]gobo.ID, 0)
+	for id := range s.following[user.id] {
+		ids = append(ids, id)
+	}
+	return s.usersJSON(ctx, ids)
+}
+
+func (s *Server) followers(ctx *apiContext) (interface{}, *apiError) {
+	user, apiErr := s.targetUser(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	ids := make([]gobo.ID, 0)
+	for follower, followees := range s.following {
+		if followees[user.id] {
+			ids = append(ids, follower)
+		}
+	}
+	return s.usersJSON(ctx, ids)
+}
+
+// 收藏相关
+
+// favorites和favorites/ids：按照收藏时间从新到旧输出当前用户的收藏，favorites/ids中只有微博的ID
+func (s *Server) listFavorites(ctx *apiContext) (interface{}, *apiError) {
+	favorites := s.favorites[ctx.uid]
+	result := make([]interface{}, 0)
+	for _, i := range page(ctx, len(favorites)) {
+		f := favorites[len(favorites)-1-i]
+		if ctx.endpoint == "favorites/ids" {
+			result = append(result, map[string]interface{}{
+				"status":         f.statusId,
+				"tags":           []interface{}{},
+				"favorited_time": f.favoritedAt.Format(time.RubyDate),
+			})
+			continue
+		}
+		favorite, apiErr := s.favoriteJSON(f)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		result = append(result, favorite)
+	}
+	return map[string]interface{}{
+		"favorites":    result,
+		"total_number": len(favorites),
+	}, nil
+}
+
+func (s *Server) showFavorite(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	for _, f := range s.favorites[ctx.uid] {
+		if f.statusId == st.id {
+			return s.favoriteJSON(f)
+		}
+	}
+	return nil, &apiError{http.StatusBadRequest, ErrorNotFavorited, "not your favorite!"}
+}
+
+func (s *Server) createFavorite(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	for _, f := range s.favorites[ctx.uid] {
+		if f.statusId == st.id {
+			return nil, &apiError{http.StatusBadRequest, ErrorAlreadyFavorited, "This status has been collected!"}
+		}
+	}
+	f := &fakeFavorite{statusId: st.id, favoritedAt: time.Now()}
+	s.favorites[ctx.uid] = append(s.favorites[ctx.uid], f)
+	return s.favoriteJSON(f)
+}
+
+func (s *Server) destroyFavorite(ctx *apiContext) (interface{}, *apiError) {
+	st, apiErr := s.findStatus(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	f := s.removeFavorite(ctx.uid, st.id)
+	if f == nil {
+		return nil, &apiError{http.StatusBadRequest, ErrorNotFavorited, "not your favorite!"}
+	}
+	return s.favoriteJSON(f)
+}
+
+// 从用户uid的收藏中删除微博statusId，返回被删除的收藏，没有收藏时返回nil
+func (s *Server) removeFavorite(uid gobo.ID, statusId gobo.ID) *fakeFavorite {
+	favorites := s.favorites[uid]
+	for i, f := range favorites {
+		if f.statusId == statusId {
+			s.favorites[uid] = append(favorites[:i], favorites[i+1:]...)
+			return f
+		}
+	}
+	return nil
+}
+
+// 输出JSON
+
+func (s *Server) favoriteJSON(f *fakeFavorite) (map[string]interface{}, *apiError) {
+	status, apiErr := s.statusJSON(s.statuses[f.statusId])
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return map[string]interface{}{
+		"status":         status,
+		"tags":           []interface{}{},
+		"favorited_time": f.favoritedAt.Format(time.RubyDate),
+	}, nil
+}
+
+func (s *Server) usersJSON(ctx *apiContext, ids []gobo.ID) (map[string]interface{}, *apiError) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	users := make([]interface{}, 0)
+	for _, i := range page(ctx, len(ids)) {
+		u, apiErr := s.findUser(ids[i])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		users = append(users, s.userJSON(u, ctx.uid, false))
+	}
+	return map[string]interface{}{
+		"users":           users,
+		"total_number":    len(ids),
+		"previous_cursor": 0,
+		"next_cursor":     0,
+	}, nil
+}
+
+// 输出用户，viewer为当前用户，withStatus表示是否包含用户最新的一条微博
+func (s *Server) userJSON(u *fakeUser, viewer gobo.ID, withStatus bool) map[string]interface{} {
+	statusesCount := 0
+	var latest *fakeStatus
+	for _, id := range s.timeline {
+		if st := s.statuses[id]; st.uid == u.id {
+			statusesCount++
+			latest = st
+		}
+	}
+	followersCount := 0
+	for _, followees := range s.following {
+		if followees[u.id] {
+			followersCount++
+		}
+	}
+	result := map[string]interface{}{
+		"id":                u.id,
+		"idstr":             u.id.String(),
+		"screen_name":       u.screenName,
+		"name":              u.screenName,
+		"profile_image_url": fmt.Sprintf("http://tp1.sinaimg.cn/%s/50/0/1", u.id),
+		"profile_url":       "u/" + u.id.String(),
+		"avatar_large":      fmt.Sprintf("http://tp1.sinaimg.cn/%s/180/0/1", u.id),
+		"followers_count":   followersCount,
+		"friends_count":     len(s.following[u.id]),
+		"statuses_count":    statusesCount,
+		"created_at":        u.createdAt.Format(time.RubyDate),
+		"following":         s.following[viewer][u.id],
+		"follow_me":         s.following[u.id][viewer],
+		"allow_all_comment": true,
+		"lang":              "zh-cn",
+	}
+	if withStatus && latest != nil {
+		result["status"] = s.statusFields(latest)
+	}
+	return result
+}
+
+// 输出微博，包括作者
+func (s *Server) statusJSON(st *fakeStatus) (map[string]interface{}, *apiError) {
+	author, apiErr := s.findUser(st.uid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	result := s.statusFields(st)
+	result["user"] = s.userJSON(author, 0, false)
+	return result, nil
+}
+
+// 输出微博中除了作者之外的字段
+func (s *Server) statusFields(st *fakeStatus) map[string]interface{} {
+	result := map[string]interface{}{
+		"created_at":      st.createdAt.Format(time.RubyDate),
+		"id":              st.id,
+		"idstr":           st.id.String(),
+		"mid":             st.id.String(),
+		"text":            st.text,
+		"source":          `<a href="http://app.weibo.com/t/feed/gobotest" rel="nofollow">gobotest</a>`,
+		"favorited":       false,
+		"truncated":       false,
+		"reposts_count":   0,
+		"comments_count":  len(st.comments),
+		"attitudes_count": 0,
+		"pic_urls":        []interface{}{},
+	}
+	if st.picId != "" {
+		thumbnail := fmt.Sprintf("http://ww1.sinaimg.cn/thumbnail/%s.jpg", st.picId)
+		result["thumbnail_pic"] = thumbnail
+		result["bmiddle_pic"] = fmt.Sprintf("http://ww1.sinaimg.cn/bmiddle/%s.jpg", st.picId)
+		result["original_pic"] = fmt.Sprintf("http://ww1.sinaimg.cn/large/%s.jpg", st.picId)
+		result["pic_urls"] = []interface{}{map[string]string{"thumbnail_pic": thumbnail}}
+	}
+	return result
+}
+
+// 输出评论，withStatus表示是否包含评论的微博
+func (s *Server) commentJSON(c *fakeComment, withStatus bool) (map[string]interface{}, *apiError) {
+	author, apiErr := s.findUser(c.uid)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	result := map[string]interface{}{
+		"created_at": c.createdAt.Format(time.RubyDate),
+		"id":         c.id,
+		"idstr":      c.id.String(),
+		"mid":        c.id.String(),
+		"text":       c.text,
+		"source":     `<a href="http://app.weibo.com/t/feed/gobotest" rel="nofollow">gobotest</a>`,
+		"user":       s.userJSON(author, 0, false),
+	}
+	if withStatus {
+		if result["status"], apiErr = s.statusJSON(s.statuses[c.statusId]); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	if replyTo, ok := s.comments[c.replyTo]; ok {
+		if result["reply_comment"], apiErr = s.commentJSON(replyTo, false); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package gobotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/huichen/gobo"
+)
+
+// 按照Server注释中的用法，通过gobo.Authenticator和gobo.Weibo走一遍授权、发微博、评论和读取时间线的流程
+func TestServerFlow(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	bob := server.AddUser("bob")
+	server.SetAuthorizer(alice)
+
+	// 授权
+	var auth gobo.Authenticator
+	auth.SetBaseURL(server.URL)
+	if err := auth.Init("http://localhost/callback", "appkey", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, &auth)
+	token, err := auth.AccessToken(code)
+	if err != nil {
+		t.Fatalf("oauth2/access_token出错：%v", err)
+	}
+	if gobo.ID(token.Uid) != alice || token.Expires_In != TokenExpiresIn || token.Access_Token == "" {
+		t.Errorf("oauth2/access_token返回 %+v", token)
+	}
+	_, err = auth.AccessToken(code)
+	expectWeiboError(t, "重复使用授权码", err, ErrorInvalidGrant)
+	info, err := auth.GetTokenInfo(token.Access_Token)
+	if err != nil {
+		t.Fatalf("oauth2/get_token_info出错：%v", err)
+	}
+	if info.Uid != alice || info.Appkey != "appkey" {
+		t.Errorf("oauth2/get_token_info返回 %+v", info)
+	}
+
+	// 发微博
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+	var status gobo.Status
+	if err := weibo.Call("statuses/update", "post", token.Access_Token, gobo.Params{"status": "你好，世界"}, &status); err != nil {
+		t.Fatalf("statuses/update出错：%v", err)
+	}
+	if status.Id == 0 || status.Text != "你好，世界" || status.User == nil || status.User.Id != alice {
+		t.Errorf("statuses/update返回 %+v", status)
+	}
+	expectKnownFields(t, "statuses/update", status.Extra, status.User.Extra)
+	err = weibo.Call("statuses/update", "post", token.Access_Token, gobo.Params{"status": "你好，世界"}, &gobo.Status{})
+	expectWeiboError(t, "重复发布", err, ErrorRepeatContent)
+
+	// 评论和回复
+	bobToken := server.IssueToken(bob)
+	var comment gobo.Comment
+	if err := weibo.Call("comments/create", "post", bobToken, gobo.Params{"id": status.Id, "comment": "沙发"}, &comment); err != nil {
+		t.Fatalf("comments/create出错：%v", err)
+	}
+	if comment.Id == 0 || comment.Text != "沙发" || comment.User == nil || comment.User.Id != bob ||
+		comment.Status == nil || comment.Status.Id != status.Id {
+		t.Errorf("comments/create返回 %+v", comment)
+	}
+	expectKnownFields(t, "comments/create", comment.Extra, comment.User.Extra, comment.Status.Extra)
+
+	var reply gobo.Comment
+	params := gobo.Params{"id": status.Id, "cid": comment.Id, "comment": "谢谢"}
+	if err := weibo.Call("comments/reply", "post", token.Access_Token, params, &reply); err != nil {
+		t.Fatalf("comments/reply出错：%v", err)
+	}
+	if reply.Text != "回复@bob:谢谢" || reply.Reply_Comment == nil || reply.Reply_Comment.Id != comment.Id {
+		t.Errorf("comments/reply返回 %+v", reply)
+	}
+	expectKnownFields(t, "comments/reply", reply.Extra, reply.Reply_Comment.Extra)
+
+	// 时间线
+	var timeline gobo.Statuses
+	if err := weibo.Call("statuses/user_timeline", "get", bobToken, gobo.Params{"uid": alice}, &timeline); err != nil {
+		t.Fatalf("statuses/user_timeline出错：%v", err)
+	}
+	if len(timeline.Statuses) != 1 {
+		t.Fatalf("statuses/user_timeline返回%d条微博，期望1条", len(timeline.Statuses))
+	}
+	if got := timeline.Statuses[0]; got.Id != status.Id || got.Comments_Count != 2 || got.User.Screen_Name != "alice" {
+		t.Errorf("statuses/user_timeline返回 %+v", got)
+	}
+
+	// 频率限制和过期的访问令牌
+	server.SetRateLimit(1)
+	if err := weibo.Call("users/show", "get", bobToken, gobo.Params{"uid": alice}, &gobo.User{}); err != nil {
+		t.Fatalf("users/show出错：%v", err)
+	}
+	err = weibo.Call("users/show", "get", bobToken, gobo.Params{"uid": alice}, &gobo.User{})
+	expectWeiboError(t, "超过频率限制", err, ErrorRateLimit)
+
+	server.SetRateLimit(0)
+	server.ExpireToken(token.Access_Token)
+	err = weibo.Call("statuses/update", "post", token.Access_Token, gobo.Params{"status": "过期了"}, &gobo.Status{})
+	expectWeiboError(t, "过期的访问令牌", err, ErrorExpiredToken)
+	err = weibo.Call("statuses/update", "post", "invalid", gobo.Params{"status": "无效"}, &gobo.Status{})
+	expectWeiboError(t, "无效的访问令牌", err, ErrorInvalidToken)
+}
+
+// 不存在的用户返回20003错误，为不存在的用户生成访问令牌时panic
+func TestServerUnknownUser(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	alice := server.AddUser("alice")
+	token := server.IssueToken(alice)
+	unknown := alice + 100
+
+	var weibo gobo.Weibo
+	weibo.SetBaseURL(server.URL)
+	err := weibo.Call("users/show", "get", token, gobo.Params{"uid": unknown}, &gobo.User{})
+	expectWeiboError(t, "users/show", err, ErrorUserNotExist)
+	err = weibo.Call("users/show", "get", token, gobo.Params{"screen_name": "nobody"}, &gobo.User{})
+	expectWeiboError(t, "users/show按昵称", err, ErrorUserNotExist)
+	err = weibo.Call("friendships/create", "post", token, gobo.Params{"uid": unknown}, &gobo.User{})
+	expectWeiboError(t, "friendships/create", err, ErrorUserNotExist)
+	err = weibo.Call("statuses/user_timeline", "get", token, gobo.Params{"uid": unknown}, &gobo.Statuses{})
+	expectWeiboError(t, "statuses/user_timeline", err, ErrorUserNotExist)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("为不存在的用户调用IssueToken没有panic")
+		}
+	}()
+	server.IssueToken(unknown)
+}
+
+// 访问授权页面，返回重定向地址中的授权码
+func authorize(t *testing.T, auth *gobo.Authenticator) string {
+	uri, err := auth.Authorize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("oauth2/authorize没有重定向：%v", err)
+	}
+	code := location.Query().Get("code")
+	if code == "" || location.Host != "localhost" {
+		t.Fatalf("oauth2/authorize重定向到 %s", location)
+	}
+	return code
+}
+
+func expectWeiboError(t *testing.T, name string, err error, code int64) {
+	t.Helper()
+	weiboErr, ok := err.(gobo.WeiboError)
+	if !ok {
+		t.Errorf("%s：期望微博错误%d，得到 %v", name, code, err)
+		return
+	}
+	if weiboErr.Error_Code != code {
+		t.Errorf("%s：错误代码为%d，期望%d", name, weiboErr.Error_Code, code)
+	}
+}
+
+// 模拟服务器输出的字段在gobo的结构体中都应当有对应的字段，否则会被保存在Extra中
+func expectKnownFields(t *testing.T, name string, extras ...map[string]json.RawMessage) {
+	t.Helper()
+	for _, extra := range extras {
+		for key := range extra {
+			t.Errorf("%s：字段%s在结构体中没有对应的字段", name, key)
+		}
+	}
+}
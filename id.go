@@ -0,0 +1,111 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// ID类型用来表达微博API中的各种ID（微博ID、用户ID、评论ID等）
+//
+// 微博的ID常常超过2^53，如果先解析为float64就会丢失精度；同时API对同一个ID有时返回
+// JSON数字（比如id），有时返回字符串（比如idstr、mid和in_reply_to_status_id）。
+// ID在解析时同时接受这两种形式且不会损失精度，空字符串和null被解析为0；编码时统一输出为JSON数字。
+type ID int64
+
+// 从十进制字符串得到ID
+func ParseID(s string) (ID, error) {
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &ErrorString{"无效的ID：" + s}
+	}
+	return ID(id), nil
+}
+
+// 返回ID的十进制字符串形式
+//
+// 由于ID实现了fmt.Stringer，ID可以直接作为Params的值使用。
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// 实现json.Marshaler接口
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// 实现json.Unmarshaler接口
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	// 字符串形式的ID
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseID(s)
+		if err != nil {
+			return err
+		}
+		*id = parsed
+		return nil
+	}
+
+	// 数字形式的ID，用json.Number保证不经过float64
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	parsed, err := number.Int64()
+	if err != nil {
+		return &ErrorString{"无效的ID：" + string(number)}
+	}
+	*id = ID(parsed)
+	return nil
+}
+
+// 实现encoding.TextMarshaler接口，使ID可以作为JSON对象的键
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// 实现encoding.TextUnmarshaler接口
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// StringID类型和ID相同，但编码为JSON字符串
+//
+// 部分API以字符串返回ID，没有值时返回空字符串，比如in_reply_to_status_id和oauth2/access_token返回的uid。
+// StringID在解析时和ID一样同时接受数字和字符串，编码时输出十进制字符串，0输出为""，因此编码后和API的格式一致。
+type StringID ID
+
+// 返回ID的十进制字符串形式
+func (id StringID) String() string {
+	return ID(id).String()
+}
+
+// 实现json.Marshaler接口
+func (id StringID) MarshalJSON() ([]byte, error) {
+	if id == 0 {
+		return []byte(`""`), nil
+	}
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// 实现json.Unmarshaler接口
+func (id *StringID) UnmarshalJSON(data []byte) error {
+	return (*ID)(id).UnmarshalJSON(data)
+}
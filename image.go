@@ -0,0 +1,373 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// 微博支持的图片格式，键为MIME类型，值为扩展名
+var supportedImageFormats = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// 根据图片内容开头的magic bytes识别图片格式
+//
+// data只需要包含图片的前512字节。返回MIME类型（比如 "image/jpeg"）和不含点的扩展名（比如 "jpg"），
+// 当格式不是微博支持的JPEG、PNG或GIF时返回非nil错误。
+func DetectImageFormat(data []byte) (mimeType string, extension string, err error) {
+	mimeType = http.DetectContentType(data)
+	extension, ok := supportedImageFormats[mimeType]
+	if !ok {
+		return "", "", &ErrorString{"不支持的图片格式：" + mimeType}
+	}
+	return mimeType, extension, nil
+}
+
+// ImageOptions结构体定义了上传之前对图片的预处理
+//
+// 见PreprocessImage函数和Weibo.SetImageOptions函数。
+type ImageOptions struct {
+	// 图片的最大宽度和高度（像素），超过时等比例缩小，为0时不限制
+	MaxWidth  int
+	MaxHeight int
+
+	// 图片的最大字节数，超过时重新编码，为0时使用MaxImageBytes
+	MaxBytes int
+
+	// 重新编码JPEG时的初始质量（1-100），为0时使用90。当编码结果超过MaxBytes时逐步降低质量
+	JPEGQuality int
+
+	// 是否去掉EXIF中的GPS信息
+	StripGPS bool
+}
+
+// 重新编码JPEG时允许的最低质量，低于此质量时改为缩小图片
+const minJPEGQuality = 50
+
+// 对图片进行上传前的预处理
+//
+// 依次进行下列处理，只使用标准库的image包：
+//  1. 当图片宽度或高度超过限制时等比例缩小
+//  2. 当图片字节数超过限制时重新编码：JPEG逐步降低质量，PNG先尝试最高压缩率，仍然超过时转为JPEG，
+//     最后逐步缩小图片直到满足限制
+//  3. 当StripGPS为真时去掉EXIF中的GPS信息（重新编码的图片本身不包含EXIF）
+//
+// GIF图片可能是动图，不做缩放和重新编码，超过字节数限制时返回错误。
+// 返回处理后的图片内容和扩展名。
+func PreprocessImage(reader io.Reader, options *ImageOptions) (*bytes.Reader, string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	_, extension, err := DetectImageFormat(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if options == nil {
+		options = &ImageOptions{}
+	}
+	maxBytes := options.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = MaxImageBytes
+	}
+
+	if extension == "gif" {
+		if len(data) > maxBytes {
+			return nil, "", &ErrorString{"GIF图片超过了大小限制"}
+		}
+		return bytes.NewReader(data), extension, nil
+	}
+
+	// 检查是否需要缩放或者重新编码
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	width, height := fitSize(config.Width, config.Height, options.MaxWidth, options.MaxHeight)
+	if width == config.Width && height == config.Height && len(data) <= maxBytes {
+		if options.StripGPS {
+			data = stripGPS(data, extension)
+		}
+		return bytes.NewReader(data), extension, nil
+	}
+
+	// 解码并重新编码
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if width != config.Width || height != config.Height {
+		img = resizeImage(img, width, height)
+	}
+	quality := options.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = 90
+	}
+	for {
+		encoded, encodedExtension, err := encodeImage(img, extension, quality, maxBytes)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(encoded) <= maxBytes {
+			return bytes.NewReader(encoded), encodedExtension, nil
+		}
+
+		// 仍然太大时缩小到原来的3/4
+		bounds := img.Bounds()
+		width, height = bounds.Dx()*3/4, bounds.Dy()*3/4
+		if width == 0 || height == 0 {
+			return nil, "", &ErrorString{"无法将图片压缩到大小限制以内"}
+		}
+		img = resizeImage(img, width, height)
+	}
+}
+
+// 编码图片，PNG无法满足大小限制时转为JPEG，JPEG在质量不低于minJPEGQuality的前提下尽量满足大小限制
+func encodeImage(img image.Image, extension string, quality int, maxBytes int) ([]byte, string, error) {
+	var buffer bytes.Buffer
+	if extension == "png" {
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buffer, img); err != nil {
+			return nil, "", err
+		}
+		if buffer.Len() <= maxBytes {
+			return buffer.Bytes(), "png", nil
+		}
+		img = flattenImage(img)
+	}
+
+	for ; ; quality -= 10 {
+		if quality < minJPEGQuality {
+			quality = minJPEGQuality
+		}
+		buffer.Reset()
+		if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", err
+		}
+		if buffer.Len() <= maxBytes || quality == minJPEGQuality {
+			return buffer.Bytes(), "jpg", nil
+		}
+	}
+}
+
+// 计算等比例缩小后的尺寸，maxWidth或maxHeight为0时不限制
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight && float64(maxHeight)/float64(height) < scale {
+		scale = float64(maxHeight) / float64(height)
+	}
+	if scale == 1.0 {
+		return width, height
+	}
+	newWidth, newHeight := int(float64(width)*scale), int(float64(height)*scale)
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+	return newWidth, newHeight
+}
+
+// 用区域平均的方法将图片缩小到width x height
+func resizeImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			// 对源图片中对应区域的像素取平均
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pixel := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(pixel.R)
+					g += uint64(pixel.G)
+					b += uint64(pixel.B)
+					a += uint64(pixel.A)
+					count++
+				}
+			}
+			dst.SetNRGBA64(x, y, color.NRGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return dst
+}
+
+// 将带透明通道的图片合成到白色背景上，用于转换为JPEG
+func flattenImage(src image.Image) image.Image {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
+
+// 去掉图片中的GPS信息，无法解析时原样返回
+func stripGPS(data []byte, extension string) []byte {
+	switch extension {
+	case "jpg":
+		return stripJPEGGPS(data)
+	case "png":
+		return stripPNGExif(data)
+	}
+	return data
+}
+
+// 清空JPEG的EXIF中的GPS IFD
+//
+// GPS IFD中的条目和它们指向的数据都被清零，其它EXIF信息（比如拍摄方向）保持不变。
+func stripJPEGGPS(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+	result := append([]byte(nil), data...)
+	for offset := 2; offset+4 <= len(result); {
+		if result[offset] != 0xFF {
+			return data
+		}
+		marker := result[offset+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// 图像数据开始，之后不再有EXIF
+			break
+		}
+		length := int(binary.BigEndian.Uint16(result[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(result) {
+			return data
+		}
+		segment := result[offset+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			if !clearGPSIFD(segment[6:]) {
+				return data
+			}
+		}
+		offset = end
+	}
+	return result
+}
+
+// EXIF数据类型对应的字节数
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// 在TIFF格式的EXIF数据中清空GPS IFD，数据格式错误时返回false
+func clearGPSIFD(tiff []byte) bool {
+	if len(tiff) < 8 {
+		return false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return false
+	}
+
+	// 在IFD0中查找GPS IFD的位置（tag 0x8825）
+	ifd0 := int(order.Uint32(tiff[4:]))
+	if ifd0+2 > len(tiff) {
+		return false
+	}
+	entries := int(order.Uint16(tiff[ifd0:]))
+	gpsIFD := -1
+	for i := 0; i < entries; i++ {
+		entry := ifd0 + 2 + i*12
+		if entry+12 > len(tiff) {
+			return false
+		}
+		if order.Uint16(tiff[entry:]) == 0x8825 {
+			gpsIFD = int(order.Uint32(tiff[entry+8:]))
+			break
+		}
+	}
+	if gpsIFD < 0 {
+		// 没有GPS信息
+		return true
+	}
+	if gpsIFD+2 > len(tiff) {
+		return false
+	}
+
+	// 清零GPS条目指向的数据，然后清零条目本身
+	gpsEntries := int(order.Uint16(tiff[gpsIFD:]))
+	if gpsIFD+2+gpsEntries*12+4 > len(tiff) {
+		return false
+	}
+	for i := 0; i < gpsEntries; i++ {
+		entry := gpsIFD + 2 + i*12
+		size := tiffTypeSizes[order.Uint16(tiff[entry+2:])] * int(order.Uint32(tiff[entry+4:]))
+		if size > 4 {
+			valueOffset := int(order.Uint32(tiff[entry+8:]))
+			if valueOffset >= 0 && valueOffset+size <= len(tiff) {
+				zero(tiff[valueOffset : valueOffset+size])
+			}
+		}
+	}
+	zero(tiff[gpsIFD : gpsIFD+2+gpsEntries*12+4])
+	return true
+}
+
+// 去掉PNG中的eXIf块
+func stripPNGExif(data []byte) []byte {
+	const signatureLength = 8
+	if len(data) < signatureLength {
+		return data
+	}
+	result := append([]byte(nil), data[:signatureLength]...)
+	for offset := signatureLength; offset < len(data); {
+		if offset+12 > len(data) {
+			return data
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return data
+		}
+		chunkType := string(data[offset+4 : offset+8])
+		if chunkType != "eXIf" {
+			result = append(result, data[offset:end]...)
+		} else if crc32.ChecksumIEEE(data[offset+4:end-4]) != binary.BigEndian.Uint32(data[end-4:]) {
+			// CRC错误说明不是合法的块，不做修改
+			return data
+		}
+		offset = end
+	}
+	return result
+}
+
+func zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
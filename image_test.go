@@ -0,0 +1,115 @@
+package gobo
+
+import (
+	"bytes"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testdata/gps.jpg是16x8的JPEG图片，EXIF中包含
+//
+//	IFD0：Make为"GoboCam"，Orientation为6，GPSInfo指向GPS IFD
+//	GPS IFD：GPSVersionID、GPSLatitudeRef为"N"、GPSLatitude为39/1 54/1 1234/100
+var (
+	gpsLatitude = []byte{0, 0, 0, 39, 0, 0, 0, 1, 0, 0, 0, 54, 0, 0, 0, 1, 0, 0, 4, 210, 0, 0, 0, 100}
+	orientation = []byte{0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 6}
+	gpsIFD      = []byte{0, 3, 0, 0, 0, 1, 0, 0, 0, 4, 2, 2, 0, 0}
+)
+
+func readGPSFixture(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testdata/gps.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, gpsLatitude) || !bytes.Contains(data, gpsIFD) {
+		t.Fatal("testdata/gps.jpg中没有GPS信息")
+	}
+	return data
+}
+
+// 检查去掉了GPS信息，其它EXIF信息和图像数据保持不变
+func checkGPSStripped(t *testing.T, original, stripped []byte) {
+	t.Helper()
+	if bytes.Contains(stripped, gpsLatitude) || bytes.Contains(stripped, gpsIFD) {
+		t.Error("GPS信息没有被去掉")
+	}
+	if !bytes.Contains(stripped, orientation) || !bytes.Contains(stripped, []byte("GoboCam\x00")) {
+		t.Error("其它EXIF信息不应当被修改")
+	}
+	if len(stripped) != len(original) {
+		t.Errorf("图片从%d字节变为%d字节", len(original), len(stripped))
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("无法解析去掉GPS信息的图片：%v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 16 || bounds.Dy() != 8 {
+		t.Errorf("图片尺寸变为%v", bounds)
+	}
+}
+
+func TestPreprocessImageStripGPS(t *testing.T) {
+	data := readGPSFixture(t)
+
+	reader, extension, err := PreprocessImage(bytes.NewReader(data), &ImageOptions{StripGPS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, _ := ioutil.ReadAll(reader)
+	if extension != "jpg" {
+		t.Errorf("扩展名为%s", extension)
+	}
+	checkGPSStripped(t, data, stripped)
+	if !bytes.Contains(data, gpsLatitude) {
+		t.Error("不应当修改输入的图片")
+	}
+
+	// 不设置StripGPS时原样返回
+	reader, _, err = PreprocessImage(bytes.NewReader(data), &ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, _ := ioutil.ReadAll(reader); !bytes.Equal(unchanged, data) {
+		t.Error("不需要处理的图片应当原样返回")
+	}
+
+	// 缩小后重新编码的图片不包含EXIF
+	reader, _, err = PreprocessImage(bytes.NewReader(data), &ImageOptions{MaxWidth: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resized, _ := ioutil.ReadAll(reader)
+	if bytes.Contains(resized, []byte("Exif\x00\x00")) {
+		t.Error("重新编码的图片不应当包含EXIF")
+	}
+	if config, err := jpeg.DecodeConfig(bytes.NewReader(resized)); err != nil || config.Width != 8 || config.Height != 4 {
+		t.Errorf("缩小后的图片为 %+v %v", config, err)
+	}
+}
+
+// 设置了SetImageOptions时上传的是去掉GPS信息的图片
+func TestUploadStripGPS(t *testing.T) {
+	data := readGPSFixture(t)
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("pic")
+		if err != nil {
+			t.Errorf("请求中没有图片：%v", err)
+			return
+		}
+		uploaded, _ = ioutil.ReadAll(file)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.SetImageOptions(&ImageOptions{StripGPS: true})
+	if err := weibo.Upload("token", Params{"status": "图片"}, bytes.NewReader(data), "", &Status{}); err != nil {
+		t.Fatal(err)
+	}
+	checkGPSStripped(t, data, uploaded)
+}
@@ -0,0 +1,135 @@
+package gobo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// 日志中需要隐藏的参数
+var redactedParams = map[string]bool{
+	"access_token":  true,
+	"client_secret": true,
+	"code":          true,
+	"refresh_token": true,
+}
+
+// 隐藏后显示的内容
+const redacted = "REDACTED"
+
+// 匹配JSON响应中需要隐藏的字段，比如oauth2/access_token返回的访问令牌
+//
+// 字符串值中可以包含转义的引号和反斜杠（比如 "a\"b"），因此按照JSON的转义规则匹配到值的结尾。
+var redactedJSONPattern = regexp.MustCompile(`"(access_token|client_secret|code|refresh_token)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+// LogOptions结构体定义了LoggingMiddleware的行为
+type LogOptions struct {
+	// 成功的请求的日志级别，默认为slog.LevelInfo。失败的请求的日志级别为Level和slog.LevelWarn中较高的一个
+	Level slog.Level
+
+	// 是否记录请求参数和响应内容。其中的访问令牌、client_secret和授权码总是被隐藏
+	LogBodies bool
+}
+
+// 用log/slog记录每个API请求的中间件
+//
+// 每个请求记录一条日志，包含API方法名（endpoint）、HTTP方法、HTTP状态码、耗时、微博错误代码、
+// 响应字节数和重试次数。访问令牌、client_secret和授权码永远不会出现在日志中。
+// options为nil时使用默认值。
+//
+// 在Weibo和Authenticator上都可以通过Use安装，比如
+//
+//	weibo.Use(gobo.LoggingMiddleware(slog.Default(), nil))
+func LoggingMiddleware(logger *slog.Logger, options *LogOptions) Middleware {
+	if options == nil {
+		options = &LogOptions{}
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			latency := time.Since(start)
+
+			level := options.Level
+			attrs := []slog.Attr{
+				slog.String("endpoint", req.Endpoint),
+				slog.String("method", req.HTTPMethod),
+				slog.Duration("latency", latency),
+			}
+			if req.Attempt > 0 {
+				attrs = append(attrs, slog.Int("attempt", req.Attempt))
+			}
+			if options.LogBodies {
+				attrs = append(attrs, slog.Any("params", RedactParams(req.Params)))
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				level = failureLevel(level)
+			} else {
+				attrs = append(attrs,
+					slog.Int("status", resp.StatusCode),
+					slog.Int("size", len(resp.Body)))
+				if weiboErr, ok := resp.APIError().(WeiboError); ok {
+					attrs = append(attrs, slog.Int64("error_code", weiboErr.Error_Code))
+				}
+				if resp.StatusCode != 200 {
+					level = failureLevel(level)
+				}
+				if options.LogBodies {
+					attrs = append(attrs, slog.String("body", RedactBody(resp.Body)))
+				}
+			}
+			logger.LogAttrs(context.Background(), level, "微博API请求", attrs...)
+			return resp, err
+		}
+	}
+}
+
+func failureLevel(level slog.Level) slog.Level {
+	if level < slog.LevelWarn {
+		return slog.LevelWarn
+	}
+	return level
+}
+
+// 返回隐藏了访问令牌、client_secret和授权码的参数副本
+func RedactParams(params Params) Params {
+	result := make(Params, len(params))
+	for k, v := range params {
+		if redactedParams[k] {
+			result[k] = redacted
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// 返回隐藏了访问令牌、client_secret和授权码的JSON响应内容
+func RedactBody(body []byte) string {
+	return redactedJSONPattern.ReplaceAllString(string(body), fmt.Sprintf(`"$1":"%s"`, redacted))
+}
+
+// 返回隐藏了query string中访问令牌、client_secret和授权码的URL
+func RedactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	query := u.Query()
+	changed := false
+	for k := range query {
+		if redactedParams[k] {
+			query.Set(k, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return rawurl
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
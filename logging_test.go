@@ -0,0 +1,124 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	cases := []struct {
+		body     string
+		redacted string
+	}{
+		{`{"access_token":"2.00abc","uid":"1"}`, `{"access_token":"REDACTED","uid":"1"}`},
+		{`{"code" : "abc", "error_code":21325}`, `{"code":"REDACTED", "error_code":21325}`},
+		// 值中带有转义的引号和反斜杠
+		{`{"access_token":"a\"b\"c","uid":"1"}`, `{"access_token":"REDACTED","uid":"1"}`},
+		{`{"client_secret":"a\\","refresh_token":"\\\"x"}`, `{"client_secret":"REDACTED","refresh_token":"REDACTED"}`},
+		// 其它字段不变
+		{`{"error":"invalid_request","error_code":21323}`, `{"error":"invalid_request","error_code":21323}`},
+	}
+	for _, c := range cases {
+		if redactedBody := RedactBody([]byte(c.body)); redactedBody != c.redacted {
+			t.Errorf("RedactBody(%s) = %s，期望 %s", c.body, redactedBody, c.redacted)
+		}
+	}
+}
+
+// 成功的请求、微博错误和网络错误的日志中都不能出现访问令牌、client_secret和授权码
+func TestLoggingMiddlewareHidesSecrets(t *testing.T) {
+	const (
+		token        = "2.00TOKENSECRET"
+		clientSecret = "CLIENTSECRET"
+		code         = "AUTHCODESECRET"
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/access_token":
+			fmt.Fprintf(w, `{"access_token":"%s","remind_in":"157679999","expires_in":157679999,"uid":"1"}`, token)
+		case "/2/users/show.json":
+			fmt.Fprint(w, `{"id":1,"screen_name":"alice"}`)
+		default:
+			// 响应中的访问令牌带有转义的引号
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error":"invalid_access_token","error_code":21332,"request":"%s","access_token":"\"%s\""}`, r.URL.Path, token)
+		}
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	middleware := LoggingMiddleware(logger, &LogOptions{LogBodies: true})
+
+	var auth Authenticator
+	auth.SetBaseURL(server.URL)
+	auth.Use(middleware)
+	if err := auth.Init("http://localhost/callback", "appkey", clientSecret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AccessToken(code); err != nil {
+		t.Fatalf("oauth2/access_token出错：%v", err)
+	}
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.Use(middleware)
+	if err := weibo.Call("users/show", "get", token, Params{"uid": 1}, &User{}); err != nil {
+		t.Fatalf("users/show出错：%v", err)
+	}
+	if err := weibo.Call("statuses/update", "post", token, Params{"status": "你好"}, &Status{}); err == nil {
+		t.Fatal("statuses/update应当返回微博错误")
+	}
+
+	// 网络错误，错误信息中的URL包含访问令牌
+	var offline Weibo
+	offline.SetBaseURL("http://127.0.0.1:1")
+	offline.Use(middleware)
+	if err := offline.Call("users/show", "get", token, Params{"uid": 1}, &User{}); err == nil {
+		t.Fatal("连接失败时应当返回错误")
+	}
+
+	output := logs.String()
+	for name, secret := range map[string]string{"访问令牌": token, "client_secret": clientSecret, "授权码": code} {
+		if strings.Contains(output, secret) {
+			t.Errorf("日志中出现了%s：\n%s", name, output)
+		}
+	}
+
+	entries := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("无法解析日志%q：%v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	expected := []struct {
+		endpoint string
+		level    string
+		field    string // 该条日志必须包含的字段
+	}{
+		{"oauth2/access_token", "INFO", "body"},
+		{"users/show", "INFO", "body"},
+		{"statuses/update", "WARN", "error_code"},
+		{"users/show", "WARN", "error"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("得到%d条日志，期望%d条：\n%s", len(entries), len(expected), output)
+	}
+	for i, e := range expected {
+		entry := entries[i]
+		if entry["endpoint"] != e.endpoint || entry["level"] != e.level || entry[e.field] == nil {
+			t.Errorf("第%d条日志为 %v，期望endpoint为%s，级别为%s，包含%s", i, entry, e.endpoint, e.level, e.field)
+		}
+		if params, ok := entry["params"].(map[string]interface{}); ok && params["access_token"] != nil && params["access_token"] != redacted {
+			t.Errorf("第%d条日志的参数中的访问令牌没有被隐藏：%v", i, params)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package gobo
+
+import (
+	"strings"
+	"sync"
+)
+
+// 展开长微博时默认的并发请求数
+const DefaultLongTextConcurrency = 4
+
+// 设置是否自动展开时间线中的长微博，concurrency为并发请求数，为0时不展开（默认）
+//
+// 设置之后通过Call调用statuses/xxx_timeline等时间线API且response为*Statuses时，
+// 其中被截断的长微博会自动通过ExpandLongText得到全文。该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) SetExpandLongText(concurrency int) {
+	weibo.longTextConcurrency = concurrency
+}
+
+// 返回微博的全文：长微博已经展开时为LongText中的全文，否则为Text
+func (status *Status) FullText() string {
+	if status.LongText != nil && status.LongText.LongTextContent != "" {
+		return status.LongText.LongTextContent
+	}
+	return status.Text
+}
+
+// 得到statuses中被截断的长微博（包括转发的原微博）的全文，填入Status.LongText
+//
+// 输入参数
+//
+//	token		用户授权的访问令牌
+//	statuses	微博，比如Statuses.Statuses
+//	concurrency	并发请求数，小于1时使用DefaultLongTextConcurrency
+//
+// 只有IsLongText为true而LongText为空的微博需要展开，每条微博调用一次statuses/show。
+// 部分微博展开失败时其它微博仍然被展开，失败的微博的LongTextError被设为对应的错误，函数返回遇到的第一个错误。
+func (weibo *Weibo) ExpandLongText(token string, statuses []*Status, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = DefaultLongTextConcurrency
+	}
+
+	// 同一条微博可能出现多次（比如被多次转发），按ID合并
+	pending := make(map[ID][]*Status)
+	ids := make([]ID, 0)
+	var collect func(status *Status)
+	collect = func(status *Status) {
+		if status == nil {
+			return
+		}
+		if status.IsLongText && status.LongText == nil {
+			if _, ok := pending[status.Id]; !ok {
+				ids = append(ids, status.Id)
+			}
+			pending[status.Id] = append(pending[status.Id], status)
+		}
+		collect(status.Retweeted_Status)
+	}
+	for _, status := range statuses {
+		collect(status)
+	}
+
+	var mutex sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for _, id := range ids {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(id ID) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			longText, err := weibo.getLongText(token, id)
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, status := range pending[id] {
+				status.LongText = longText
+				status.LongTextError = err
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(id)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// 调用statuses/show得到一条长微博的全文
+func (weibo *Weibo) getLongText(token string, id ID) (*LongText, error) {
+	var status Status
+	params := Params{"id": id, "isGetLongText": 1}
+	if err := weibo.Call("statuses/show", "get", token, params, &status); err != nil {
+		return nil, err
+	}
+	if status.LongText == nil {
+		return nil, &ErrorString{"微博" + id.String() + "没有返回全文"}
+	}
+	return status.LongText, nil
+}
+
+// 当设置了自动展开且调用的是时间线API时展开response中的长微博
+//
+// 时间线本身已经成功得到，因此展开失败不作为错误返回，而是记录在对应微博的LongTextError中
+func (weibo *Weibo) expandTimeline(endpoint string, token string, response interface{}) {
+	if weibo.longTextConcurrency <= 0 || !strings.HasSuffix(endpoint, "_timeline") {
+		return
+	}
+	statuses, ok := response.(*Statuses)
+	if !ok {
+		return
+	}
+	weibo.ExpandLongText(token, statuses.Statuses, weibo.longTextConcurrency)
+}
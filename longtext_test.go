@@ -0,0 +1,62 @@
+package gobo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandTimelinePartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/statuses/home_timeline.json":
+			fmt.Fprint(w, `{"statuses":[
+				{"id":1,"text":"第一条","isLongText":true},
+				{"id":2,"text":"第二条","isLongText":true},
+				{"id":3,"text":"转发","retweeted_status":{"id":1,"isLongText":true}}]}`)
+		case "/2/statuses/show.json":
+			id := r.URL.Query().Get("id")
+			if id == "2" {
+				// 已经被删除的微博
+				w.WriteHeader(400)
+				fmt.Fprint(w, `{"error":"target weibo does not exist!","error_code":20101,"request":"/2/statuses/show.json"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"id":%s,"isLongText":true,"longText":{"longTextContent":"全文%s"}}`, id, id)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.SetExpandLongText(2)
+	var statuses Statuses
+	// 时间线本身成功得到，展开失败不应当作为Call的错误
+	if err := weibo.Call("statuses/home_timeline", "get", "token", nil, &statuses); err != nil {
+		t.Fatalf("statuses/home_timeline出错：%v", err)
+	}
+
+	if len(statuses.Statuses) != 3 {
+		t.Fatalf("得到%d条微博，期望3条", len(statuses.Statuses))
+	}
+	expected := []string{"全文1", "第二条", "转发"}
+	for i, status := range statuses.Statuses {
+		if text := status.FullText(); text != expected[i] {
+			t.Errorf("第%d条微博的FullText = %q，期望 %q", i, text, expected[i])
+		}
+	}
+	for i, status := range statuses.Statuses {
+		if failed := status.LongTextError != nil; failed != (i == 1) {
+			t.Errorf("第%d条微博的LongTextError = %v", i, status.LongTextError)
+		}
+	}
+	if _, ok := statuses.Statuses[1].LongTextError.(WeiboError); !ok || statuses.Statuses[1].LongText != nil {
+		t.Errorf("展开失败的微博的LongTextError = %v，LongText = %v", statuses.Statuses[1].LongTextError, statuses.Statuses[1].LongText)
+	}
+	if text := statuses.Statuses[2].Retweeted_Status.FullText(); text != "全文1" {
+		t.Errorf("转发的原微博的FullText = %q，期望 %q", text, "全文1")
+	}
+}
@@ -0,0 +1,273 @@
+package gobo
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的请求耗时直方图分桶，单位为秒
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 表示触发了微博频率限制的错误代码
+var rateLimitErrorCodes = map[int64]bool{
+	10022: true, // IP请求频次超过上限
+	10023: true, // 用户请求频次超过上限
+	10024: true, // 用户请求特殊接口频次超过上限
+}
+
+// Metrics结构体收集API调用的统计数据
+//
+// 统计数据按照应用（app）和API方法名（endpoint）分别记录，包括
+//
+//	gobo_requests_total			请求数，按HTTP状态码区分，网络错误的状态码为"error"
+//	gobo_request_duration_seconds		请求耗时的直方图
+//	gobo_errors_total			微博错误数，按错误代码区分
+//	gobo_rate_limited_total			触发频率限制的请求数
+//	gobo_retries_total			重试的请求数
+//
+// Metrics实现了http.Handler，以Prometheus文本格式输出统计数据；也可以通过PublishExpvar发布到expvar。
+// 一个Metrics可以同时用于多个Weibo和Authenticator结构体。
+type Metrics struct {
+	mutex       sync.Mutex
+	buckets     []float64
+	requests    map[metricKey]int64
+	errors      map[metricKey]int64
+	rateLimited map[metricKey]int64
+	retries     map[metricKey]int64
+	latencies   map[metricKey]*histogram
+}
+
+// 统计数据的标签，不需要的标签为空字符串
+type metricKey struct {
+	app      string
+	endpoint string
+	label    string // requests中为HTTP状态码，errors中为错误代码
+}
+
+type histogram struct {
+	counts []int64 // 和Metrics.buckets一一对应，不累积
+	count  int64
+	sum    float64
+}
+
+// 生成Metrics结构体，buckets为请求耗时直方图的分桶上限（单位为秒，从小到大），为nil时使用DefaultLatencyBuckets
+func NewMetrics(buckets []float64) *Metrics {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets:     buckets,
+		requests:    make(map[metricKey]int64),
+		errors:      make(map[metricKey]int64),
+		rateLimited: make(map[metricKey]int64),
+		retries:     make(map[metricKey]int64),
+		latencies:   make(map[metricKey]*histogram),
+	}
+}
+
+// 返回记录统计数据的中间件，app为应用名（比如App Key），用于区分不同应用的统计数据
+//
+// 为了统计每一次重试，该中间件应当安装在RetryMiddleware之后，比如
+//
+//	weibo.Use(gobo.RetryMiddleware(3, time.Second), metrics.Middleware("myapp"))
+func (m *Metrics) Middleware(app string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			m.observe(app, req, resp, err, time.Since(start))
+			return resp, err
+		}
+	}
+}
+
+func (m *Metrics) observe(app string, req *Request, resp *Response, err error, latency time.Duration) {
+	key := metricKey{app: app, endpoint: req.Endpoint}
+	status := "error"
+	var weiboErr WeiboError
+	isWeiboErr := false
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		weiboErr, isWeiboErr = resp.APIError().(WeiboError)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[metricKey{app: app, endpoint: req.Endpoint, label: status}]++
+	if isWeiboErr {
+		m.errors[metricKey{app: app, endpoint: req.Endpoint, label: strconv.FormatInt(weiboErr.Error_Code, 10)}]++
+		if rateLimitErrorCodes[weiboErr.Error_Code] {
+			m.rateLimited[key]++
+		}
+	}
+	if req.Attempt > 0 {
+		m.retries[key]++
+	}
+
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{counts: make([]int64, len(m.buckets))}
+		m.latencies[key] = h
+	}
+	seconds := latency.Seconds()
+	for i, bucket := range m.buckets {
+		if seconds <= bucket {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// 实现http.Handler接口，以Prometheus文本格式输出统计数据
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// 以Prometheus文本格式输出统计数据
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "gobo_requests_total", "微博API请求数", "status", m.requests)
+	writeCounter(&b, "gobo_errors_total", "微博API返回的错误数", "error_code", m.errors)
+	writeCounter(&b, "gobo_rate_limited_total", "触发微博频率限制的请求数", "", m.rateLimited)
+	writeCounter(&b, "gobo_retries_total", "重试的微博API请求数", "", m.retries)
+
+	name := "gobo_request_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s 微博API请求耗时\n# TYPE %s histogram\n", name, name)
+	keys := make([]metricKey, 0, len(m.latencies))
+	for key := range m.latencies {
+		keys = append(keys, key)
+	}
+	for _, key := range sortKeys(keys) {
+		h := m.latencies[key]
+		labels := formatLabels(key, "")
+		var cumulative int64
+		for i, bucket := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bucket), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCounter(b *strings.Builder, name string, help string, labelName string, values map[metricKey]int64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]metricKey, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	for _, key := range sortKeys(keys) {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, formatLabels(key, labelName), values[key])
+	}
+}
+
+// 生成Prometheus标签，labelName为空时不输出key.label
+func formatLabels(key metricKey, labelName string) string {
+	labels := fmt.Sprintf("app=\"%s\",endpoint=\"%s\"", escapeLabel(key.app), escapeLabel(key.endpoint))
+	if labelName != "" {
+		labels += fmt.Sprintf(",%s=\"%s\"", labelName, escapeLabel(key.label))
+	}
+	return labels
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// 按照app、endpoint和label排序，使输出稳定
+func sortKeys(keys []metricKey) []metricKey {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].app != keys[j].app {
+			return keys[i].app < keys[j].app
+		}
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].label < keys[j].label
+	})
+	return keys
+}
+
+// 实现expvar.Var接口，以JSON格式输出统计数据
+//
+// 输出的格式为 {"应用": {"API方法名": {"requests": {...}, "errors": {...}, ...}}}。
+func (m *Metrics) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	type endpointStats struct {
+		Requests       map[string]int64 `json:"requests"`
+		Errors         map[string]int64 `json:"errors"`
+		RateLimited    int64            `json:"rate_limited"`
+		Retries        int64            `json:"retries"`
+		Count          int64            `json:"count"`
+		LatencySeconds float64          `json:"latency_seconds_sum"`
+	}
+	result := make(map[string]map[string]*endpointStats)
+	stats := func(key metricKey) *endpointStats {
+		if result[key.app] == nil {
+			result[key.app] = make(map[string]*endpointStats)
+		}
+		s := result[key.app][key.endpoint]
+		if s == nil {
+			s = &endpointStats{Requests: make(map[string]int64), Errors: make(map[string]int64)}
+			result[key.app][key.endpoint] = s
+		}
+		return s
+	}
+	for key, value := range m.requests {
+		stats(key).Requests[key.label] = value
+	}
+	for key, value := range m.errors {
+		stats(key).Errors[key.label] = value
+	}
+	for key, value := range m.rateLimited {
+		stats(key).RateLimited = value
+	}
+	for key, value := range m.retries {
+		stats(key).Retries = value
+	}
+	for key, h := range m.latencies {
+		stats(key).Count = h.count
+		stats(key).LatencySeconds = h.sum
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// 将统计数据以name为名发布到expvar（通常通过/debug/vars访问）
+//
+// 同一个name只能发布一次，重复发布会导致expvar panic。
+func (m *Metrics) PublishExpvar(name string) {
+	expvar.Publish(name, m)
+}
@@ -0,0 +1,89 @@
+package gobo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 并发请求时统计数据准确，并且可以同时输出
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := NewMetrics([]float64{1, 0.5})
+	rateLimited := []byte(`{"error":"User requests out of rate limit!","error_code":10023,"request":"/2/statuses/update.json"}`)
+	handler := metrics.Middleware("app")(func(req *Request) (*Response, error) {
+		switch req.Endpoint {
+		case "statuses/update":
+			return &Response{StatusCode: 403, Body: rateLimited}, nil
+		case "users/show":
+			return &Response{StatusCode: 200, Body: []byte(`{}`)}, nil
+		}
+		return nil, errors.New("连接失败")
+	})
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			handler(&Request{Endpoint: "users/show", HTTPMethod: "get"})
+		}()
+		go func(attempt int) {
+			defer wg.Done()
+			handler(&Request{Endpoint: "statuses/update", HTTPMethod: "post", Attempt: attempt})
+		}(i % 2)
+		go func() {
+			defer wg.Done()
+			handler(&Request{Endpoint: "comments/show", HTTPMethod: "get"})
+		}()
+		// 统计的同时输出
+		go func() {
+			defer wg.Done()
+			metrics.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+			_ = metrics.String()
+		}()
+	}
+	wg.Wait()
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	output := recorder.Body.String()
+	for _, line := range []string{
+		`gobo_requests_total{app="app",endpoint="comments/show",status="error"} 50`,
+		`gobo_requests_total{app="app",endpoint="statuses/update",status="403"} 50`,
+		`gobo_requests_total{app="app",endpoint="users/show",status="200"} 50`,
+		`gobo_errors_total{app="app",endpoint="statuses/update",error_code="10023"} 50`,
+		`gobo_rate_limited_total{app="app",endpoint="statuses/update"} 50`,
+		`gobo_retries_total{app="app",endpoint="statuses/update"} 25`,
+		// 分桶按照从小到大排序，计数累积
+		`gobo_request_duration_seconds_bucket{app="app",endpoint="users/show",le="0.5"} 50`,
+		`gobo_request_duration_seconds_bucket{app="app",endpoint="users/show",le="1"} 50`,
+		`gobo_request_duration_seconds_bucket{app="app",endpoint="users/show",le="+Inf"} 50`,
+		`gobo_request_duration_seconds_count{app="app",endpoint="users/show"} 50`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("输出中没有 %s\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, `gobo_retries_total{app="app",endpoint="users/show"}`) {
+		t.Error("没有重试的请求不应当有gobo_retries_total")
+	}
+
+	var stats map[string]map[string]struct {
+		Requests    map[string]int64 `json:"requests"`
+		Errors      map[string]int64 `json:"errors"`
+		RateLimited int64            `json:"rate_limited"`
+		Retries     int64            `json:"retries"`
+		Count       int64            `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(metrics.String()), &stats); err != nil {
+		t.Fatalf("无法解析expvar输出：%v", err)
+	}
+	update := stats["app"]["statuses/update"]
+	if update.Requests["403"] != n || update.Errors["10023"] != n || update.RateLimited != n || update.Retries != n/2 || update.Count != n {
+		t.Errorf("expvar输出中statuses/update的统计为 %+v", update)
+	}
+}
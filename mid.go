@@ -0,0 +1,254 @@
+package gobo
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 微博URL中使用的base62字母表
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// 微博分享链接（比如 https://weibo.com/1642591402/KdnYhqrA4 ）中的最后一段是mid的base62编码。
+//
+// 编码方法是：将十进制的mid从右向左每7位分为一组，每组转换为base62，除了最左边一组外都补足4位，
+// 然后依次拼接。解码则是从右向左每4个字符分为一组，每组转换为十进制，除了最左边一组外都补足7位。
+
+// 将微博的mid（十进制字符串，同Status.Mid和Status.Idstr）转换为URL中的base62编码
+func MidToURL(mid string) (string, error) {
+	if mid == "" || strings.Trim(mid, "0123456789") != "" {
+		return "", &ErrorString{"无效的mid：" + mid}
+	}
+
+	var code string
+	for end := len(mid); end > 0; end -= 7 {
+		start := end - 7
+		if start < 0 {
+			start = 0
+		}
+		n, _ := strconv.ParseInt(mid[start:end], 10, 64)
+		group := encodeBase62(n)
+		if start > 0 {
+			group = strings.Repeat("0", 4-len(group)) + group
+		}
+		code = group + code
+	}
+	return code, nil
+}
+
+// 将URL中的base62编码转换为微博的mid（十进制字符串）
+func URLToMid(code string) (string, error) {
+	if code == "" || len(code) > 16 {
+		return "", &ErrorString{"无效的微博URL编码：" + code}
+	}
+
+	var mid string
+	for end := len(code); end > 0; end -= 4 {
+		start := end - 4
+		if start < 0 {
+			start = 0
+		}
+		n, ok := decodeBase62(code[start:end])
+		if !ok {
+			return "", &ErrorString{"无效的微博URL编码：" + code}
+		}
+		group := strconv.FormatInt(n, 10)
+		if start > 0 {
+			// 除了最左边一组外每组最多7位，比如 "ZZZZ" 解码为8位的14776335，不是有效的编码
+			if len(group) > 7 {
+				return "", &ErrorString{"无效的微博URL编码：" + code}
+			}
+			group = strings.Repeat("0", 7-len(group)) + group
+		}
+		mid = group + mid
+	}
+
+	// 去掉首部多余的0
+	mid = strings.TrimLeft(mid, "0")
+	if mid == "" {
+		mid = "0"
+	}
+	return mid, nil
+}
+
+func encodeBase62(n int64) string {
+	if n == 0 {
+		return "0"
+	}
+	var buf []byte
+	for n > 0 {
+		buf = append([]byte{base62Alphabet[n%62]}, buf...)
+		n /= 62
+	}
+	return string(buf)
+}
+
+func decodeBase62(s string) (int64, bool) {
+	var n int64
+	for i := 0; i < len(s); i++ {
+		index := strings.IndexByte(base62Alphabet, s[i])
+		if index < 0 {
+			return 0, false
+		}
+		n = n*62 + int64(index)
+	}
+	return n, true
+}
+
+// 微博URL的类型
+const (
+	URLTypeStatus = "status" // 单条微博
+	URLTypeUser   = "user"   // 用户主页
+	URLTypeTopic  = "topic"  // 话题
+)
+
+// WeiboURL结构体为ParseURL的解析结果
+type WeiboURL struct {
+	Type  string // URLTypeStatus、URLTypeUser或URLTypeTopic之一
+	Uid   ID     // 用户ID，URL中不包含时为0
+	Mid   string // 微博的mid（十进制字符串），仅当Type为URLTypeStatus时有效
+	Id    ID     // 和Mid对应的数字ID
+	Code  string // 微博的base62编码，仅当Type为URLTypeStatus时有效
+	Topic string // 话题名（不含#），仅当Type为URLTypeTopic时有效
+}
+
+var (
+	digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+	base62Pattern = regexp.MustCompile(`^[0-9a-zA-Z]+$`)
+	topicPattern  = regexp.MustCompile(`^#([^#]+)#$`)
+)
+
+// 解析weibo.com和m.weibo.cn的微博、用户和话题URL
+//
+// 支持的格式包括
+//
+//	https://weibo.com/<uid>/<base62或mid>		微博
+//	https://m.weibo.cn/status/<base62或mid>		微博
+//	https://m.weibo.cn/detail/<mid>			微博
+//	https://m.weibo.cn/<uid>/<mid>			微博
+//	https://weibo.com/u/<uid>			用户
+//	https://weibo.com/<uid>				用户（uid必须是纯数字，个性域名无法解析）
+//	https://m.weibo.cn/u/<uid>			用户
+//	https://m.weibo.cn/profile/<uid>		用户
+//	https://s.weibo.com/weibo?q=%23话题%23		话题
+//	https://m.weibo.cn/search?containerid=...&q=%23话题%23	话题
+//	https://huati.weibo.com/k/<话题>			话题
+//
+// 当URL无法识别时返回非nil错误
+func ParseURL(rawurl string) (*WeiboURL, error) {
+	if !strings.Contains(rawurl, "://") {
+		rawurl = "https://" + rawurl
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	switch host {
+	case "weibo.com", "weibo.cn", "m.weibo.cn", "m.weibo.com":
+		if len(segments) == 2 && (segments[0] == "u" || segments[0] == "profile") {
+			return parseUserURL(segments[1])
+		}
+		if len(segments) == 2 && (segments[0] == "status" || segments[0] == "detail") {
+			return parseStatusURL("", segments[1])
+		}
+		if len(segments) == 1 && segments[0] == "search" {
+			return parseTopicURL(u.Query().Get("q"))
+		}
+		if len(segments) == 1 && digitsPattern.MatchString(segments[0]) {
+			return parseUserURL(segments[0])
+		}
+		if len(segments) == 2 && digitsPattern.MatchString(segments[0]) {
+			return parseStatusURL(segments[0], segments[1])
+		}
+	case "s.weibo.com":
+		if len(segments) >= 1 && segments[0] == "weibo" {
+			if len(segments) == 2 {
+				return parseTopicURL(segments[1])
+			}
+			return parseTopicURL(u.Query().Get("q"))
+		}
+	case "huati.weibo.com":
+		if len(segments) == 2 && segments[0] == "k" {
+			return &WeiboURL{Type: URLTypeTopic, Topic: segments[1]}, nil
+		}
+	}
+	return nil, &ErrorString{"无法识别的微博URL：" + rawurl}
+}
+
+func parseUserURL(uid string) (*WeiboURL, error) {
+	id, err := ParseID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return &WeiboURL{Type: URLTypeUser, Uid: id}, nil
+}
+
+func parseStatusURL(uid string, code string) (*WeiboURL, error) {
+	result := &WeiboURL{Type: URLTypeStatus}
+	if uid != "" {
+		id, err := ParseID(uid)
+		if err != nil {
+			return nil, err
+		}
+		result.Uid = id
+	}
+
+	// 纯数字且长度超过base62编码可能的长度时视为mid，否则视为base62编码
+	var err error
+	if digitsPattern.MatchString(code) && len(code) > 9 {
+		result.Mid = strings.TrimLeft(code, "0")
+		result.Code, err = MidToURL(result.Mid)
+	} else if base62Pattern.MatchString(code) {
+		result.Code = code
+		result.Mid, err = URLToMid(code)
+	} else {
+		return nil, &ErrorString{"无效的微博URL编码：" + code}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Id, err = ParseID(result.Mid)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseTopicURL(query string) (*WeiboURL, error) {
+	matches := topicPattern.FindStringSubmatch(query)
+	if matches == nil {
+		return nil, &ErrorString{"无效的话题：" + query}
+	}
+	return &WeiboURL{Type: URLTypeTopic, Topic: matches[1]}, nil
+}
+
+// 返回微博在weibo.com上的URL
+//
+// 需要Status.User和Status.Mid（或Status.Idstr）不为空，否则返回空字符串。
+func (status *Status) URL() string {
+	if status.User == nil || status.User.Id == 0 {
+		return ""
+	}
+	mid := status.Mid
+	if mid == "" {
+		mid = status.Idstr
+	}
+	if mid == "" && status.Id != 0 {
+		mid = status.Id.String()
+	}
+	code, err := MidToURL(mid)
+	if err != nil {
+		return ""
+	}
+	return "https://weibo.com/" + status.User.Id.String() + "/" + code
+}
@@ -0,0 +1,98 @@
+package gobo
+
+import (
+	"testing"
+)
+
+func TestMidToURL(t *testing.T) {
+	cases := []struct {
+		mid  string
+		code string
+	}{
+		{"4626395862221397", "Kb4FY9jSZ"},
+		{"3501756485200075", "z0JH2lOMb"},
+		{"1", "1"},
+		{"10000001", "10001"}, // 非最左边的组补足4位
+		{"0", "0"},
+	}
+	for _, c := range cases {
+		code, err := MidToURL(c.mid)
+		if err != nil {
+			t.Errorf("MidToURL(%q)出错：%v", c.mid, err)
+			continue
+		}
+		if code != c.code {
+			t.Errorf("MidToURL(%q) = %q，期望 %q", c.mid, code, c.code)
+		}
+		mid, err := URLToMid(code)
+		if err != nil {
+			t.Errorf("URLToMid(%q)出错：%v", code, err)
+			continue
+		}
+		if mid != c.mid {
+			t.Errorf("URLToMid(%q) = %q，期望 %q", code, mid, c.mid)
+		}
+	}
+}
+
+func TestMidToURLInvalid(t *testing.T) {
+	for _, mid := range []string{"", "12a3", "-1", " 123"} {
+		if code, err := MidToURL(mid); err == nil {
+			t.Errorf("MidToURL(%q) = %q，期望出错", mid, code)
+		}
+	}
+}
+
+func TestURLToMidInvalid(t *testing.T) {
+	for _, code := range []string{
+		"",
+		"Kb4F-9jSZ",
+		"12345678901234567", // 超过16个字符
+		"1ZZZZ",             // 非最左边的组解码为8位数字
+	} {
+		if mid, err := URLToMid(code); err == nil {
+			t.Errorf("URLToMid(%q) = %q，期望出错", code, mid)
+		}
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	cases := []struct {
+		url    string
+		expect WeiboURL
+	}{
+		{"https://weibo.com/1642591402/Kb4FY9jSZ",
+			WeiboURL{Type: URLTypeStatus, Uid: 1642591402, Mid: "4626395862221397", Id: 4626395862221397, Code: "Kb4FY9jSZ"}},
+		{"m.weibo.cn/status/4626395862221397",
+			WeiboURL{Type: URLTypeStatus, Mid: "4626395862221397", Id: 4626395862221397, Code: "Kb4FY9jSZ"}},
+		{"https://m.weibo.cn/detail/4626395862221397",
+			WeiboURL{Type: URLTypeStatus, Mid: "4626395862221397", Id: 4626395862221397, Code: "Kb4FY9jSZ"}},
+		{"https://weibo.com/u/1642591402", WeiboURL{Type: URLTypeUser, Uid: 1642591402}},
+		{"https://www.weibo.com/1642591402", WeiboURL{Type: URLTypeUser, Uid: 1642591402}},
+		{"https://s.weibo.com/weibo?q=%23话题%23", WeiboURL{Type: URLTypeTopic, Topic: "话题"}},
+		{"https://huati.weibo.com/k/话题", WeiboURL{Type: URLTypeTopic, Topic: "话题"}},
+	}
+	for _, c := range cases {
+		result, err := ParseURL(c.url)
+		if err != nil {
+			t.Errorf("ParseURL(%q)出错：%v", c.url, err)
+			continue
+		}
+		if *result != c.expect {
+			t.Errorf("ParseURL(%q) = %+v，期望 %+v", c.url, *result, c.expect)
+		}
+	}
+}
+
+func TestParseURLInvalid(t *testing.T) {
+	for _, u := range []string{
+		"https://weibo.com/123/aZZZZ",
+		"https://weibo.com/rmrb",
+		"https://example.com/123/Kb4FY9jSZ",
+		"https://s.weibo.com/weibo?q=话题",
+	} {
+		if result, err := ParseURL(u); err == nil {
+			t.Errorf("ParseURL(%q) = %+v，期望出错", u, *result)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package gobo
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Request结构体表示一次微博API请求，在中间件之间传递
+type Request struct {
+	Endpoint   string  // API方法名，比如 "statuses/update" 又如 "oauth2/access_token"
+	HTTPMethod string  // HTTP请求方式，"get"或者"post"
+	Token      string  // 用户授权的访问令牌，部分授权接口中为空
+	Params     Params  // 输入参数，见Params结构体的注释
+	Upload     *Upload // 上传的图片，不上传图片时为nil
+	Attempt    int     // 第几次重试，首次请求为0，见RetryMiddleware
+}
+
+// Upload结构体表示请求中上传的图片
+//
+// 图片内容是流式读取的，因此包含Upload的请求只能发送一次。
+type Upload struct {
+	Reader   io.Reader    // 包含图片的二进制流
+	Progress ProgressFunc // 上传进度回调函数，可以为nil
+}
+
+// Response结构体表示API服务器返回的原始内容
+type Response struct {
+	StatusCode int         // HTTP状态码
+	Header     http.Header // HTTP响应头
+	Body       []byte      // 未经解析的响应内容，通常为JSON
+}
+
+// 当HTTP状态码不是200时返回API服务器返回的错误，否则返回nil
+//
+// 返回的错误通常是WeiboError，当响应内容无法解析时返回解析错误。
+func (resp *Response) APIError() error {
+	if resp.StatusCode == 200 {
+		return nil
+	}
+	var weiboErr WeiboError
+	if err := json.Unmarshal(resp.Body, &weiboErr); err != nil {
+		return err
+	}
+	return weiboErr
+}
+
+// 将响应内容还原到response中，HTTP状态码不是200时返回错误
+func (resp *Response) decode(response interface{}) error {
+	if err := resp.APIError(); err != nil {
+		return err
+	}
+	return decodeResponse(resp.Body, response)
+}
+
+// Handler处理一次API请求并返回API服务器的原始响应
+//
+// 只有在无法得到响应（比如网络错误）时返回非nil错误，API服务器返回的错误包含在Response中，见Response.APIError。
+type Handler func(req *Request) (*Response, error)
+
+// Middleware（中间件）包装一个Handler并返回新的Handler
+//
+// 中间件可以在请求发送前修改请求、在得到响应后检查或替换响应，也可以不调用next而直接返回响应（比如缓存）。
+// 中间件通过Weibo.Use和Authenticator.Use安装。
+type Middleware func(next Handler) Handler
+
+// 将中间件依次包装在handler外面，middlewares中的第一个中间件在最外层
+func chainMiddlewares(middlewares []Middleware, handler Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// 微博API返回的表示服务器暂时不可用的错误代码
+var transientErrorCodes = map[int64]bool{
+	10001: true, // 系统错误
+	10002: true, // 服务暂停
+	10009: true, // 任务过多，系统繁忙
+}
+
+// 失败时重试的中间件
+//
+// 输入参数
+//
+//	maxRetries	最多重试的次数
+//	backoff		第一次重试之前等待的时间，之后每次重试等待的时间加倍
+//
+// GET请求在网络错误、HTTP 5xx错误和表示服务器繁忙的微博错误时重试。为了避免重复发布，
+// POST请求只在服务器返回表示繁忙的微博错误（见transientErrorCodes）时重试：网络错误和
+// 不带微博错误的5xx（比如网关返回的502、504）不能说明请求没有生效，因此不重试；上传图片的请求不重试。
+// 重试时Request.Attempt被设置为重试的次数。
+func RetryMiddleware(maxRetries int, backoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			wait := backoff
+			for attempt := 0; ; attempt++ {
+				attemptReq := *req
+				attemptReq.Attempt = attempt
+				resp, err := next(&attemptReq)
+				if attempt >= maxRetries || req.Upload != nil || !isRetryable(req, resp, err) {
+					return resp, err
+				}
+				time.Sleep(wait)
+				wait *= 2
+			}
+		}
+	}
+}
+
+func isRetryable(req *Request, resp *Response, err error) bool {
+	if err != nil {
+		return req.HTTPMethod == "get"
+	}
+	if req.HTTPMethod == "get" && resp.StatusCode >= 500 {
+		return true
+	}
+	weiboErr, ok := resp.APIError().(WeiboError)
+	return ok && transientErrorCodes[weiboErr.Error_Code]
+}
+
+// 限制请求频率的中间件
+//
+// 使用令牌桶算法：平均每秒最多发送requestsPerSecond个请求，允许最多burst个请求的突发。
+// 超过频率的请求会被阻塞直到可以发送。同一个中间件安装在多个Weibo结构体上时共享同一个频率限制。
+// requestsPerSecond不大于0时不限制频率，请求直接发送。
+func RateLimitMiddleware(requestsPerSecond float64, burst int) Middleware {
+	if !(requestsPerSecond > 0) {
+		return func(next Handler) Handler {
+			return next
+		}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := &rateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			limiter.wait()
+			return next(req)
+		}
+	}
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	interval time.Duration // 产生一个令牌的时间
+	burst    int
+	tokens   float64
+	last     time.Time
+}
+
+// 取得一个令牌，没有令牌时阻塞
+func (limiter *rateLimiter) wait() {
+	limiter.Lock()
+	now := time.Now()
+	limiter.tokens += float64(now.Sub(limiter.last)) / float64(limiter.interval)
+	if limiter.tokens > float64(limiter.burst) {
+		limiter.tokens = float64(limiter.burst)
+	}
+	limiter.last = now
+	limiter.tokens--
+	var delay time.Duration
+	if limiter.tokens < 0 {
+		delay = time.Duration(-limiter.tokens * float64(limiter.interval))
+	}
+	limiter.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,61 @@
+package gobo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	busy := []byte(`{"error":"too many pending tasks","error_code":10009,"request":"/2/statuses/update.json"}`)
+	cases := []struct {
+		name       string
+		httpMethod string
+		resp       *Response
+		calls      int
+	}{
+		{"GET遇到502重试", "get", &Response{StatusCode: 502, Body: []byte("Bad Gateway")}, 3},
+		{"GET遇到繁忙重试", "get", &Response{StatusCode: 400, Body: busy}, 3},
+		{"POST遇到502不重试", "post", &Response{StatusCode: 502, Body: []byte("Bad Gateway")}, 1},
+		{"POST遇到504不重试", "post", &Response{StatusCode: 504, Body: []byte(`{}`)}, 1},
+		{"POST遇到繁忙重试", "post", &Response{StatusCode: 503, Body: busy}, 3},
+		{"成功不重试", "post", &Response{StatusCode: 200, Body: []byte(`{}`)}, 1},
+	}
+	for _, c := range cases {
+		calls := 0
+		handler := RetryMiddleware(2, 0)(func(req *Request) (*Response, error) {
+			if req.Attempt != calls {
+				t.Errorf("%s：Attempt = %d，期望 %d", c.name, req.Attempt, calls)
+			}
+			calls++
+			return c.resp, nil
+		})
+		handler(&Request{Endpoint: "statuses/update", HTTPMethod: c.httpMethod})
+		if calls != c.calls {
+			t.Errorf("%s：请求了%d次，期望%d次", c.name, calls, c.calls)
+		}
+	}
+}
+
+// requestsPerSecond不大于0时不限制频率
+func TestRateLimitMiddlewareNoLimit(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		calls := 0
+		handler := RateLimitMiddleware(rate, 1)(func(req *Request) (*Response, error) {
+			calls++
+			return &Response{StatusCode: 200}, nil
+		})
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			if _, err := handler(&Request{Endpoint: "users/show", HTTPMethod: "get"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if calls != 100 {
+			t.Errorf("RateLimitMiddleware(%v, 1)：请求了%d次，期望100次", rate, calls)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("RateLimitMiddleware(%v, 1)：100个请求用了%v，不应当被限制频率", rate, elapsed)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gobo
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"sync/atomic"
+)
+
+// 上传进度回调函数
+//
+// sent为已经发送的字节数，total为请求体的总字节数，总字节数未知时为-1。
+// 回调函数在发送数据的goroutine中被调用，不应当阻塞。
+type ProgressFunc func(sent int64, total int64)
+
+// multipartBody是流式的multipart请求体
+//
+// 表单字段和文件头部在生成时被写入内存（它们很小），文件内容则在HTTP请求发送时才通过io.Pipe从reader中读取。
+type multipartBody struct {
+	*io.PipeReader
+	contentType   string
+	contentLength int64 // 未知时为-1
+	progress      ProgressFunc
+	sent          int64
+}
+
+// 生成流式的multipart请求体，图片内容放在"pic"字段中
+//
+// 图片的格式根据内容开头的magic bytes识别，见DetectImageFormat函数。
+func newMultipartBody(params Params, reader io.Reader, progress ProgressFunc) (*multipartBody, error) {
+	// 读取图片的前512字节用于识别格式
+	sniffed := make([]byte, 512)
+	n, err := io.ReadFull(reader, sniffed)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	sniffed = sniffed[:n]
+	mimeType, extension, err := DetectImageFormat(sniffed)
+	if err != nil {
+		return nil, err
+	}
+	size := readerSize(reader)
+	if size >= 0 {
+		size += int64(n)
+	}
+	content := io.MultiReader(bytes.NewReader(sniffed), reader)
+
+	// 生成图片内容之前和之后的部分
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for k, values := range params.values() {
+		for _, value := range values {
+			if err := writer.WriteField(k, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="pic"; filename="image.`+extension+`"`)
+	header.Set("Content-Type", mimeType)
+	if _, err := writer.CreatePart(header); err != nil {
+		return nil, err
+	}
+	head := append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	tail := append([]byte(nil), buffer.Bytes()...)
+
+	body := &multipartBody{
+		contentType:   writer.FormDataContentType(),
+		contentLength: -1,
+		progress:      progress,
+	}
+	if size >= 0 {
+		body.contentLength = int64(len(head)) + size + int64(len(tail))
+	}
+
+	// 在单独的goroutine中写入请求体，读取reader出错时该错误将作为HTTP请求的错误返回
+	pipeReader, pipeWriter := io.Pipe()
+	body.PipeReader = pipeReader
+	go func() {
+		if _, err := pipeWriter.Write(head); err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(pipeWriter, content); err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		if _, err := pipeWriter.Write(tail); err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		pipeWriter.Close()
+	}()
+	return body, nil
+}
+
+// 实现io.Reader接口，同时报告发送进度
+func (body *multipartBody) Read(p []byte) (int, error) {
+	n, err := body.PipeReader.Read(p)
+	if n > 0 && body.progress != nil {
+		body.progress(atomic.AddInt64(&body.sent, int64(n)), body.contentLength)
+	}
+	return n, err
+}
+
+// 得到reader中剩余的字节数，无法得到时返回-1
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	case io.Seeker:
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - offset
+	}
+	return -1
+}
@@ -0,0 +1,130 @@
+package gobo
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 生成随机像素的PNG图片，压缩后仍然有几十KB，请求体需要分多次发送
+func noisyImage(t *testing.T) []byte {
+	img := image.NewGray(image.Rect(0, 0, 200, 200))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// 隐藏Len和Seek方法，长度未知的reader
+type plainReader struct {
+	io.Reader
+}
+
+// 读到一半出错的reader
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("读取图片出错")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// 流式发送的请求体长度和Content-Length一致，长度未知时使用chunked编码
+func TestMultipartBodyLength(t *testing.T) {
+	type received struct {
+		contentLength    int64
+		bodyLength       int
+		transferEncoding []string
+		pic              []byte
+	}
+	var last received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("读取请求体出错：%v", err)
+			return
+		}
+		last = received{contentLength: r.ContentLength, bodyLength: len(body), transferEncoding: r.TransferEncoding}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		file, _, err := r.FormFile("pic")
+		if err != nil {
+			t.Errorf("请求中没有图片：%v", err)
+			return
+		}
+		last.pic, _ = ioutil.ReadAll(file)
+		if r.FormValue("status") != "图片" {
+			t.Errorf("status参数为%q", r.FormValue("status"))
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	pic := noisyImage(t)
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := ioutil.WriteFile(path, pic, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, c := range []struct {
+		name   string
+		reader io.Reader
+		known  bool
+	}{
+		{"bytes.Reader", bytes.NewReader(pic), true},
+		{"文件", file, true},
+		{"长度未知", plainReader{bytes.NewReader(pic)}, false},
+	} {
+		var sent, total int64
+		progress := func(s, tt int64) {
+			sent, total = s, tt
+		}
+		if err := weibo.UploadWithProgress("token", Params{"status": "图片"}, c.reader, "", progress, &Status{}); err != nil {
+			t.Errorf("%s：上传出错：%v", c.name, err)
+			continue
+		}
+		if !bytes.Equal(last.pic, pic) {
+			t.Errorf("%s：服务器收到的图片不完整", c.name)
+		}
+		if sent != int64(last.bodyLength) {
+			t.Errorf("%s：进度报告发送了%d字节，实际为%d字节", c.name, sent, last.bodyLength)
+		}
+		if c.known {
+			if last.contentLength != int64(last.bodyLength) || total != last.contentLength || len(last.transferEncoding) != 0 {
+				t.Errorf("%s：Content-Length为%d，total为%d，请求体为%d字节，Transfer-Encoding为%v",
+					c.name, last.contentLength, total, last.bodyLength, last.transferEncoding)
+			}
+		} else if last.contentLength != -1 || total != -1 || len(last.transferEncoding) != 1 || last.transferEncoding[0] != "chunked" {
+			t.Errorf("%s：Content-Length为%d，total为%d，Transfer-Encoding为%v", c.name, last.contentLength, total, last.transferEncoding)
+		}
+	}
+
+	// 读取reader的错误作为上传的错误返回
+	err = weibo.Upload("token", Params{"status": "图片"}, &failingReader{data: pic[:len(pic)/2]}, "", &Status{})
+	if err == nil || !strings.Contains(err.Error(), "读取图片出错") {
+		t.Errorf("读取出错时返回 %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package gobo
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 微博图片的尺寸，对应图片URL路径的第一段，比如
+//
+//	http://ww2.sinaimg.cn/thumbnail/6b4ed3fajw1e2j8ycvzmxj.jpg
+//
+// Status.Original_Pic使用的就是PicSizeLarge。
+const (
+	PicSizeThumbnail = "thumbnail" // 缩略图
+	PicSizeBmiddle   = "bmiddle"   // 中等尺寸
+	PicSizeMw690     = "mw690"     // 宽度不超过690像素
+	PicSizeLarge     = "large"     // 原图
+)
+
+// 将图片URL改写为指定的尺寸
+//
+// size为PicSizeThumbnail等常数之一。当URL不是新浪图床的格式时原样返回。
+func PicURL(picUrl string, size string) string {
+	u, err := url.Parse(picUrl)
+	if err != nil || !strings.HasSuffix(u.Host, ".sinaimg.cn") {
+		return picUrl
+	}
+	segments := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(segments) != 2 {
+		return picUrl
+	}
+	u.Path = "/" + size + "/" + segments[1]
+	return u.String()
+}
+
+// 得到微博及其转发的原微博中所有图片在指定尺寸下的URL
+//
+// size为PicSizeThumbnail等常数之一。多图微博从Pic_Urls中得到所有图片，
+// 否则使用Thumbnail_Pic。返回的URL按照出现顺序排列且没有重复。
+func (status *Status) Pics(size string) []string {
+	pics := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, s := range []*Status{status, status.Retweeted_Status} {
+		if s == nil {
+			continue
+		}
+		sources := make([]string, 0, len(s.Pic_Urls)+1)
+		for _, pic := range s.Pic_Urls {
+			if pic != nil && pic.Thumbnail_Pic != "" {
+				sources = append(sources, pic.Thumbnail_Pic)
+			}
+		}
+		if len(sources) == 0 {
+			for _, pic := range []string{s.Thumbnail_Pic, s.Bmiddle_Pic, s.Original_Pic} {
+				if pic != "" {
+					sources = append(sources, pic)
+					break
+				}
+			}
+		}
+
+		for _, source := range sources {
+			pic := PicURL(source, size)
+			if !seen[pic] {
+				seen[pic] = true
+				pics = append(pics, pic)
+			}
+		}
+	}
+	return pics
+}
@@ -0,0 +1,160 @@
+package gobo
+
+// search/suggestions/schools的学校类型
+const (
+	SchoolTypeAll        = 0 // 全部
+	SchoolTypeUniversity = 1 // 大学
+	SchoolTypeHighSchool = 2 // 高中
+	SchoolTypeSecondary  = 3 // 中专技校
+	SchoolTypeMiddle     = 4 // 初中
+	SchoolTypePrimary    = 5 // 小学
+)
+
+// search/suggestions/at_users的联想范围
+const (
+	AtUsersFollowing = 0 // 从关注的人中联想
+	AtUsersFollowers = 1 // 从粉丝中联想
+)
+
+// search/suggestions/at_users匹配的字段
+const (
+	AtUsersMatchNickname = 0 // 只匹配昵称
+	AtUsersMatchRemark   = 1 // 只匹配备注
+	AtUsersMatchAll      = 2 // 匹配昵称和备注
+)
+
+// SearchService封装了搜索联想相关的API，通过Weibo.Search得到
+//
+// 所有函数的第一个参数都是用户授权的访问令牌，q为用户输入的关键词，count为返回的结果数，为0时使用API的默认值。
+type SearchService struct {
+	weibo *Weibo
+}
+
+// 返回搜索联想相关的API
+func (weibo *Weibo) Search() *SearchService {
+	return &SearchService{weibo: weibo}
+}
+
+// 调用search/suggestions/users，联想用户
+func (service *SearchService) Users(token string, q string, count int) ([]*SuggestedUser, error) {
+	users := make([]*SuggestedUser, 0)
+	err := service.weibo.Call("search/suggestions/users", "get", token, searchParams(q, count), &users)
+	return users, err
+}
+
+// 调用search/suggestions/statuses，联想微博搜索关键词
+func (service *SearchService) Statuses(token string, q string, count int) ([]*SearchSuggestion, error) {
+	suggestions := make([]*SearchSuggestion, 0)
+	err := service.weibo.Call("search/suggestions/statuses", "get", token, searchParams(q, count), &suggestions)
+	return suggestions, err
+}
+
+// 调用search/suggestions/schools，联想学校，schoolType见SchoolTypeAll等常数
+func (service *SearchService) Schools(token string, q string, count int, schoolType int) ([]*SuggestedSchool, error) {
+	params := searchParams(q, count)
+	params["type"] = schoolType
+	schools := make([]*SuggestedSchool, 0)
+	err := service.weibo.Call("search/suggestions/schools", "get", token, params, &schools)
+	return schools, err
+}
+
+// 调用search/suggestions/companies，联想公司
+func (service *SearchService) Companies(token string, q string, count int) ([]*SearchSuggestion, error) {
+	suggestions := make([]*SearchSuggestion, 0)
+	err := service.weibo.Call("search/suggestions/companies", "get", token, searchParams(q, count), &suggestions)
+	return suggestions, err
+}
+
+// 调用search/suggestions/apps，联想应用
+func (service *SearchService) Apps(token string, q string, count int) ([]*SuggestedApp, error) {
+	apps := make([]*SuggestedApp, 0)
+	err := service.weibo.Call("search/suggestions/apps", "get", token, searchParams(q, count), &apps)
+	return apps, err
+}
+
+// 调用search/suggestions/at_users，在输入@时联想用户
+//
+// 输入参数
+//
+//	token		用户授权的访问令牌
+//	q		@之后用户输入的内容，可以包含中文和任意字符
+//	count		返回的结果数，为0时使用API的默认值
+//	atType		联想范围，AtUsersFollowing或者AtUsersFollowers
+//	atRange		匹配的字段，AtUsersMatchNickname、AtUsersMatchRemark或者AtUsersMatchAll
+func (service *SearchService) AtUsers(token string, q string, count int, atType int, atRange int) ([]*AtUser, error) {
+	params := searchParams(q, count)
+	params["type"] = atType
+	params["range"] = atRange
+	users := make([]*AtUser, 0)
+	err := service.weibo.Call("search/suggestions/at_users", "get", token, params, &users)
+	return users, err
+}
+
+func searchParams(q string, count int) Params {
+	params := Params{"q": q}
+	if count > 0 {
+		params["count"] = count
+	}
+	return params
+}
+
+// SuggestionsService封装了推荐相关的API，通过Weibo.Suggestions得到
+//
+// 所有函数的第一个参数都是用户授权的访问令牌；分页的函数中count为每页的数量，page为页码（从1开始），
+// 为0时使用API的默认值。
+type SuggestionsService struct {
+	weibo *Weibo
+}
+
+// 返回推荐相关的API
+func (weibo *Weibo) Suggestions() *SuggestionsService {
+	return &SuggestionsService{weibo: weibo}
+}
+
+// 调用suggestions/users/hot，得到热门用户，category为分类，比如 "ent" 又如 "sports"，为空时返回默认分类
+func (service *SuggestionsService) HotUsers(token string, category string) ([]*User, error) {
+	users := make([]*User, 0)
+	err := service.weibo.Call("suggestions/users/hot", "get", token, Params{"category": category}, &users)
+	return users, err
+}
+
+// 调用suggestions/users/may_interested，得到当前用户可能感兴趣的用户
+func (service *SuggestionsService) MayInterested(token string, count int, page int) ([]*InterestedUser, error) {
+	users := make([]*InterestedUser, 0)
+	err := service.weibo.Call("suggestions/users/may_interested", "get", token, pageParams(count, page), &users)
+	return users, err
+}
+
+// 调用suggestions/users/by_status，根据微博内容content推荐用户，num为返回的用户数，为0时使用API的默认值
+func (service *SuggestionsService) UsersByStatus(token string, content string, num int) ([]*User, error) {
+	params := Params{"content": content}
+	if num > 0 {
+		params["num"] = num
+	}
+	var result struct {
+		Users []*User `json:"users"`
+	}
+	err := service.weibo.Call("suggestions/users/by_status", "get", token, params, &result)
+	if result.Users == nil {
+		result.Users = make([]*User, 0)
+	}
+	return result.Users, err
+}
+
+// 调用suggestions/favorites/hot，得到热门收藏
+func (service *SuggestionsService) HotFavorites(token string, count int, page int) ([]*Status, error) {
+	statuses := make([]*Status, 0)
+	err := service.weibo.Call("suggestions/favorites/hot", "get", token, pageParams(count, page), &statuses)
+	return statuses, err
+}
+
+// 调用suggestions/statuses/reorder，得到按照兴趣重新排序的当前用户的时间线
+//
+// section为需要排序的微博数（按时间从新到旧），比如100。
+func (service *SuggestionsService) ReorderStatuses(token string, section int, count int, page int) (*Statuses, error) {
+	params := pageParams(count, page)
+	params["section"] = section
+	var statuses Statuses
+	err := service.weibo.Call("suggestions/statuses/reorder", "get", token, params, &statuses)
+	return &statuses, err
+}
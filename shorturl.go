@@ -0,0 +1,162 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// 每次请求最多包含的短链接或者长链接数
+const MaxShortURLsPerRequest = 20
+
+// Count类型用来表达API返回的计数
+//
+// 短链接相关的API有时以字符串（比如 "123"）、有时以数字返回计数，Count在解析时同时接受两种形式，编码时输出为JSON数字。
+type Count int64
+
+// 实现json.Unmarshaler接口
+func (count *Count) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	// 字符串形式的计数，空字符串为0
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*count = 0
+			return nil
+		}
+	} else {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		s = string(number)
+	}
+	parsed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return &ErrorString{"无效的计数：" + s}
+	}
+	*count = Count(parsed)
+	return nil
+}
+
+// ShortURLService封装了短链接相关的API，通过Weibo.ShortURL得到
+//
+// 所有函数的第一个参数都是用户授权的访问令牌。接受多个链接的函数在链接数超过MaxShortURLsPerRequest时
+// 自动分成多次请求，返回的结果和输入的顺序一致；出错时返回已经得到的结果和错误。
+type ShortURLService struct {
+	weibo *Weibo
+}
+
+// 返回短链接相关的API
+func (weibo *Weibo) ShortURL() *ShortURLService {
+	return &ShortURLService{weibo: weibo}
+}
+
+// 调用short_url/shorten，将长链接转换成短链接
+func (service *ShortURLService) Shorten(token string, longURLs []string) ([]*Url_Short, error) {
+	result := make([]*Url_Short, 0, len(longURLs))
+	for _, chunk := range splitURLs(longURLs) {
+		var response struct {
+			Urls []*Url_Short `json:"urls"`
+		}
+		if err := service.weibo.Call("short_url/shorten", "get", token, Params{"url_long": chunk}, &response); err != nil {
+			return result, err
+		}
+		result = append(result, response.Urls...)
+	}
+	return result, nil
+}
+
+// 调用short_url/expand，将短链接还原成长链接
+func (service *ShortURLService) Expand(token string, shortURLs []string) ([]*Url_Short, error) {
+	result := make([]*Url_Short, 0, len(shortURLs))
+	for _, chunk := range splitURLs(shortURLs) {
+		var response struct {
+			Urls []*Url_Short `json:"urls"`
+		}
+		if err := service.weibo.Call("short_url/expand", "get", token, Params{"url_short": chunk}, &response); err != nil {
+			return result, err
+		}
+		result = append(result, response.Urls...)
+	}
+	return result, nil
+}
+
+// 调用short_url/clicks，得到短链接的总点击数
+func (service *ShortURLService) Clicks(token string, shortURLs []string) ([]*ShortURLClicks, error) {
+	result := make([]*ShortURLClicks, 0, len(shortURLs))
+	for _, chunk := range splitURLs(shortURLs) {
+		var response struct {
+			Urls []*ShortURLClicks `json:"urls"`
+		}
+		if err := service.weibo.Call("short_url/clicks", "get", token, Params{"url_short": chunk}, &response); err != nil {
+			return result, err
+		}
+		result = append(result, response.Urls...)
+	}
+	return result, nil
+}
+
+// 调用short_url/referers，得到一个短链接按来源网址统计的点击数
+func (service *ShortURLService) Referers(token string, shortURL string) (*ShortURLReferers, error) {
+	var referers ShortURLReferers
+	err := service.weibo.Call("short_url/referers", "get", token, Params{"url_short": shortURL}, &referers)
+	return &referers, err
+}
+
+// 调用short_url/locations，得到一个短链接按地区统计的点击数
+func (service *ShortURLService) Locations(token string, shortURL string) (*ShortURLLocations, error) {
+	var locations ShortURLLocations
+	err := service.weibo.Call("short_url/locations", "get", token, Params{"url_short": shortURL}, &locations)
+	return &locations, err
+}
+
+// 调用short_url/share/counts，得到短链接在微博上被分享的次数
+func (service *ShortURLService) ShareCounts(token string, shortURLs []string) ([]*ShortURLShareCounts, error) {
+	result := make([]*ShortURLShareCounts, 0, len(shortURLs))
+	for _, chunk := range splitURLs(shortURLs) {
+		var response struct {
+			Urls []*ShortURLShareCounts `json:"urls"`
+		}
+		if err := service.weibo.Call("short_url/share/counts", "get", token, Params{"url_short": chunk}, &response); err != nil {
+			return result, err
+		}
+		result = append(result, response.Urls...)
+	}
+	return result, nil
+}
+
+// 调用short_url/comment/counts，得到短链接在微博上被评论的次数
+func (service *ShortURLService) CommentCounts(token string, shortURLs []string) ([]*ShortURLCommentCounts, error) {
+	result := make([]*ShortURLCommentCounts, 0, len(shortURLs))
+	for _, chunk := range splitURLs(shortURLs) {
+		var response struct {
+			Urls []*ShortURLCommentCounts `json:"urls"`
+		}
+		if err := service.weibo.Call("short_url/comment/counts", "get", token, Params{"url_short": chunk}, &response); err != nil {
+			return result, err
+		}
+		result = append(result, response.Urls...)
+	}
+	return result, nil
+}
+
+// 将链接分成每组最多MaxShortURLsPerRequest个
+func splitURLs(urls []string) [][]string {
+	chunks := make([][]string, 0, (len(urls)+MaxShortURLsPerRequest-1)/MaxShortURLsPerRequest)
+	for start := 0; start < len(urls); start += MaxShortURLsPerRequest {
+		end := start + MaxShortURLsPerRequest
+		if end > len(urls) {
+			end = len(urls)
+		}
+		chunks = append(chunks, urls[start:end])
+	}
+	return chunks
+}
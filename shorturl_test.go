@@ -0,0 +1,34 @@
+package gobo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCountUnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		json  string
+		count Count
+	}{
+		{`123`, 123},
+		{`"123"`, 123},
+		{`""`, 0},
+		{`null`, 0},
+		{`"9007199254740993"`, 9007199254740993},
+	}
+	for _, c := range cases {
+		var count Count
+		if err := json.Unmarshal([]byte(c.json), &count); err != nil || count != c.count {
+			t.Errorf("解析%s得到 %d %v，期望%d", c.json, count, err, c.count)
+		}
+	}
+
+	for _, invalid := range []string{`"abc"`, `1.5`, `"1e3"`} {
+		var count Count
+		err := json.Unmarshal([]byte(invalid), &count)
+		if err == nil || !strings.Contains(err.Error(), "无效的计数") {
+			t.Errorf("解析%s返回 %v，期望无效的计数错误", invalid, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gobo
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -13,123 +14,208 @@ import (
 //
 //	JSON字段:			golang结构体字段:
 //	name_of_a_field			Name_Of_A_Field
+//
+// 每个字段都带有对应的json标签，因此结构体编码后的JSON和微博API的格式一致，可以保存之后再解析。
+// 只有API不一定返回的字段带有omitempty，API总是返回的字段即使为空（""、0、null、[]）也会输出，
+// 因此API返回的JSON解析之后再编码不会改变，见structs_test.go。
+//
+// 各种ID字段统一使用ID类型，API以字符串返回的ID使用StringID类型，见id.go
+//
+// Status、Comment和User中没有对应字段的JSON字段保存在Extra中，编码时原样输出，见extra.go
 
 type Status struct {
-	Created_At              string
-	Id                      int64
-	Mid                     string
-	Text                    string
-	Idstr                   string
-	Source                  string
-	Favorited               bool
-	Trucated                bool
-	In_Reply_To_Status_Id   string
-	In_Reply_To_User_Id     string
-	In_Reply_To_Screen_Name string
-	Thumbnail_Pic           string
-	Bmiddle_Pic             string
-	Original_Pic            string
-	Geo                     *Geo
-	User                    *User
-	Retweeted_Status        *Status
-	Reposts_Count           int
-	Comments_Count          int
-	Attitudes_Count         int
-	Mlevel                  int
-	Visible                 *Visible
-	Pic_Urls                []*Pic_Url
+	Created_At              string            `json:"created_at"`
+	Id                      ID                `json:"id"`
+	Mid                     string            `json:"mid"`
+	Text                    string            `json:"text"`
+	Idstr                   string            `json:"idstr"`
+	Source                  string            `json:"source"`
+	Favorited               bool              `json:"favorited"`
+	Truncated               bool              `json:"truncated"`
+	In_Reply_To_Status_Id   StringID          `json:"in_reply_to_status_id"`
+	In_Reply_To_User_Id     StringID          `json:"in_reply_to_user_id"`
+	In_Reply_To_Screen_Name string            `json:"in_reply_to_screen_name"`
+	Thumbnail_Pic           string            `json:"thumbnail_pic,omitempty"`
+	Bmiddle_Pic             string            `json:"bmiddle_pic,omitempty"`
+	Original_Pic            string            `json:"original_pic,omitempty"`
+	Geo                     *Geo              `json:"geo"`
+	User                    *User             `json:"user,omitempty"`
+	Retweeted_Status        *Status           `json:"retweeted_status,omitempty"`
+	Reposts_Count           int               `json:"reposts_count"`
+	Comments_Count          int               `json:"comments_count"`
+	Attitudes_Count         int               `json:"attitudes_count"`
+	Mlevel                  int               `json:"mlevel"`
+	Visible                 *Visible          `json:"visible"`
+	Pic_Urls                []*Pic_Url        `json:"pic_urls"`
+	Pic_Ids                 []string          `json:"pic_ids,omitempty"`
+	Annotations             []json.RawMessage `json:"annotations,omitempty"` // 内容由发布微博的应用决定，因此保留原始JSON
+	Deleted                 string            `json:"deleted,omitempty"`     // 微博已被删除时为"1"
+	IsLongText              bool              `json:"isLongText"`
+	LongText                *LongText         `json:"longText,omitempty"`  // 长微博的全文，见Weibo.ExpandLongText
+	Page_Info               json.RawMessage   `json:"page_info,omitempty"` // 视频、文章等卡片，不同类型的结构不同，因此保留原始JSON
+	Edit_Count              int               `json:"edit_count,omitempty"`
+	Region_Name             string            `json:"region_name,omitempty"` // 发布地区，比如 "发布于 北京"
+
+	LongTextError error                      `json:"-"` // 展开长微博失败时的错误，此时LongText为nil，见Weibo.ExpandLongText
+	Extra         map[string]json.RawMessage `json:"-"`
+}
+
+// 长微博的全文
+type LongText struct {
+	LongTextContent string `json:"longTextContent"`
 }
 
 type Comment struct {
-	Created_At    string
-	Id            int64
-	Text          string
-	Source        string
-	User          *User
-	Mid           string
-	Idstr         string
-	Status        string
-	Reply_Comment *Comment
+	Created_At      string   `json:"created_at"`
+	Id              ID       `json:"id"`
+	Text            string   `json:"text"`
+	Source          string   `json:"source"`
+	User            *User    `json:"user,omitempty"`
+	Mid             string   `json:"mid"`
+	Idstr           string   `json:"idstr"`
+	Status          *Status  `json:"status,omitempty"`
+	Reply_Comment   *Comment `json:"reply_comment,omitempty"`
+	Rootid          ID       `json:"rootid"` // 楼中楼评论所属的根评论
+	Floor_Number    int      `json:"floor_number"`
+	Like_Count      int      `json:"like_count"`
+	Reposts_Count   int      `json:"reposts_count,omitempty"`
+	Attitudes_Count int      `json:"attitudes_count,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type User struct {
-	Id                 int64
-	Idstr              string
-	Screen_Name        string
-	Name               string
-	Province           string
-	City               string
-	Location           string
-	Description        string
-	Url                string
-	Profile_Image_Url  string
-	Profile_Url        string
-	Domain             string
-	Weihao             string
-	Gender             string
-	Followers_Count    int
-	Friends_Count      int
-	Statuses_Count     int
-	Favourites_Count   int
-	Created_At         string
-	Following          bool
-	Allow_All_Act_Msg  bool
-	Geo_Enabled        bool
-	Verified           bool
-	Verified_Type      int
-	Remark             string
-	Status             *Status
-	Allow_All_Comment  bool
-	Avatar_Large       string
-	Verified_Reason    string
-	Follow_Me          bool
-	Online_Status      int
-	Bi_Followers_Count int
-	Lang               string
+	Id                  ID      `json:"id"`
+	Idstr               string  `json:"idstr"`
+	Screen_Name         string  `json:"screen_name"`
+	Name                string  `json:"name"`
+	Province            string  `json:"province"`
+	City                string  `json:"city"`
+	Location            string  `json:"location"`
+	Description         string  `json:"description"`
+	Url                 string  `json:"url"`
+	Profile_Image_Url   string  `json:"profile_image_url"`
+	Profile_Url         string  `json:"profile_url"`
+	Domain              string  `json:"domain"`
+	Weihao              string  `json:"weihao"`
+	Gender              string  `json:"gender"`
+	Followers_Count     int     `json:"followers_count"`
+	Friends_Count       int     `json:"friends_count"`
+	Statuses_Count      int     `json:"statuses_count"`
+	Favourites_Count    int     `json:"favourites_count"`
+	Created_At          string  `json:"created_at"`
+	Following           bool    `json:"following"`
+	Allow_All_Act_Msg   bool    `json:"allow_all_act_msg"`
+	Geo_Enabled         bool    `json:"geo_enabled"`
+	Verified            bool    `json:"verified"`
+	Verified_Type       int     `json:"verified_type"`
+	Remark              string  `json:"remark"`
+	Status              *Status `json:"status,omitempty"`
+	Allow_All_Comment   bool    `json:"allow_all_comment"`
+	Avatar_Large        string  `json:"avatar_large"`
+	Verified_Reason     string  `json:"verified_reason"`
+	Follow_Me           bool    `json:"follow_me"`
+	Online_Status       int     `json:"online_status"`
+	Bi_Followers_Count  int     `json:"bi_followers_count"`
+	Lang                string  `json:"lang"`
+	Cover_Image         string  `json:"cover_image,omitempty"`
+	Cover_Image_Phone   string  `json:"cover_image_phone,omitempty"`
+	Avatar_Hd           string  `json:"avatar_hd"`
+	Verified_Reason_Url string  `json:"verified_reason_url"`
+	Urank               int     `json:"urank"`
+	Mbrank              int     `json:"mbrank"`
+	Mbtype              int     `json:"mbtype"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type Privacy struct {
-	Comment  int
-	Geo      int
-	Message  int
-	Realname int
-	Badge    int
-	Mobile   int
-	Webim    int
+	Comment  int `json:"comment"`
+	Geo      int `json:"geo"`
+	Message  int `json:"message"`
+	Realname int `json:"realname"`
+	Badge    int `json:"badge"`
+	Mobile   int `json:"mobile"`
+	Webim    int `json:"webim"`
 }
 
 type Remind struct {
-	Status         int
-	Follower       int
-	Cmt            int
-	Dm             int
-	Mention_Status int
-	Mention_Cmt    int
-	Group          int
-	Private_Group  int
-	Notice         int
-	Invite         int
-	Badge          int
-	Photo          int
+	Status         int `json:"status"`
+	Follower       int `json:"follower"`
+	Cmt            int `json:"cmt"`
+	Dm             int `json:"dm"`
+	Mention_Status int `json:"mention_status"`
+	Mention_Cmt    int `json:"mention_cmt"`
+	Group          int `json:"group"`
+	Private_Group  int `json:"private_group"`
+	Notice         int `json:"notice"`
+	Invite         int `json:"invite"`
+	Badge          int `json:"badge"`
+	Photo          int `json:"photo"`
 }
 
 type Url_Short struct {
-	Url_Short string
-	Url_Long  string
-	Type      int
-	Result    bool
+	Url_Short string `json:"url_short"`
+	Url_Long  string `json:"url_long"`
+	Type      int    `json:"type"`
+	Result    bool   `json:"result"`
+}
+
+// short_url/clicks返回的点击数
+type ShortURLClicks struct {
+	Url_Short string `json:"url_short"`
+	Url_Long  string `json:"url_long"`
+	Clicks    Count  `json:"clicks"`
+}
+
+// short_url/referers返回的来源统计
+type ShortURLReferers struct {
+	Url_Short string             `json:"url_short"`
+	Url_Long  string             `json:"url_long"`
+	Referers  []*ShortURLReferer `json:"referers"`
+}
+
+type ShortURLReferer struct {
+	Referer string `json:"referer"`
+	Clicks  Count  `json:"clicks"`
+}
+
+// short_url/locations返回的地区统计
+type ShortURLLocations struct {
+	Url_Short string              `json:"url_short"`
+	Url_Long  string              `json:"url_long"`
+	Locations []*ShortURLLocation `json:"locations"`
+}
+
+type ShortURLLocation struct {
+	Location string `json:"location"`
+	Clicks   Count  `json:"clicks"`
+}
+
+// short_url/share/counts返回的被分享次数
+type ShortURLShareCounts struct {
+	Url_Short    string `json:"url_short"`
+	Url_Long     string `json:"url_long"`
+	Share_Counts Count  `json:"share_counts"`
+}
+
+// short_url/comment/counts返回的被评论次数
+type ShortURLCommentCounts struct {
+	Url_Short      string `json:"url_short"`
+	Url_Long       string `json:"url_long"`
+	Comment_Counts Count  `json:"comment_counts"`
 }
 
 type Geo struct {
-	Longitude     string
-	Latitude      string
-	City          string
-	Province      string
-	City_Name     string
-	Province_Name string
-	Address       string
-	Pinyin        string
-	More          string
+	Longitude     string `json:"longitude"`
+	Latitude      string `json:"latitude"`
+	City          string `json:"city"`
+	Province      string `json:"province"`
+	City_Name     string `json:"city_name"`
+	Province_Name string `json:"province_name"`
+	Address       string `json:"address"`
+	Pinyin        string `json:"pinyin"`
+	More          string `json:"more"`
 }
 
 // 其他的常用结构体
@@ -143,9 +229,9 @@ func (e *ErrorString) Error() string {
 }
 
 type WeiboError struct {
-	Err        string `json:"Error"`
-	Error_Code int64
-	Request    string
+	Err        string `json:"error"`
+	Error_Code int64  `json:"error_code"`
+	Request    string `json:"request"`
 }
 
 func (e WeiboError) Error() string {
@@ -153,29 +239,109 @@ func (e WeiboError) Error() string {
 }
 
 type AccessToken struct {
-	Access_Token string
-	Remind_In    string
-	Expires_In   int
-	Uid          string
+	Access_Token string   `json:"access_token"`
+	Remind_In    string   `json:"remind_in"`
+	Expires_In   int      `json:"expires_in"`
+	Uid          StringID `json:"uid"`
+	IsRealName   string   `json:"isRealName,omitempty"` // 用户是否实名认证，"true"或者"false"
 }
 
 type AccessTokenInfo struct {
-	Uid        int64
-	Appkey     string
-	Scope      string
-	Created_At int
-	Expire_In  int
+	Uid        ID     `json:"uid"`
+	Appkey     string `json:"appkey"`
+	Scope      string `json:"scope"` // 授权范围，没有时API返回null，解析为""
+	Created_At int    `json:"create_at"`
+	Expire_In  int    `json:"expire_in"`
 }
 
 type Statuses struct {
-	Statuses []*Status
+	Statuses []*Status `json:"statuses"`
+}
+
+type Favorite struct {
+	Status         *Status        `json:"status,omitempty"`
+	Tags           []*FavoriteTag `json:"tags,omitempty"`
+	Favorited_Time string         `json:"favorited_time"`
+}
+
+// favorites/ids返回的收藏，其中只有微博的ID
+type FavoriteId struct {
+	Status         ID             `json:"status"`
+	Tags           []*FavoriteTag `json:"tags,omitempty"`
+	Favorited_Time string         `json:"favorited_time"`
+}
+
+type FavoriteTag struct {
+	Id    ID     `json:"id"`
+	Tag   string `json:"tag"`
+	Count int    `json:"count,omitempty"` // 使用该标签的收藏数，只在favorites/tags中返回
+}
+
+type Favorites struct {
+	Favorites    []*Favorite `json:"favorites,omitempty"`
+	Total_Number int         `json:"total_number"`
+}
+
+type FavoriteIds struct {
+	Favorites    []*FavoriteId `json:"favorites,omitempty"`
+	Total_Number int           `json:"total_number"`
+}
+
+type FavoriteTags struct {
+	Tags         []*FavoriteTag `json:"tags,omitempty"`
+	Total_Number int            `json:"total_number"`
+}
+
+// search/suggestions/users返回的用户
+type SuggestedUser struct {
+	Uid             ID     `json:"uid"`
+	Screen_Name     string `json:"screen_name"`
+	Followers_Count int    `json:"followers_count"`
+}
+
+// search/suggestions/statuses和search/suggestions/companies返回的搜索建议
+type SearchSuggestion struct {
+	Suggestion string `json:"suggestion"`
+	Count      int    `json:"count,omitempty"`
+}
+
+type SuggestedSchool struct {
+	Id          ID     `json:"id"`
+	School_Name string `json:"school_name"`
+	Location    string `json:"location"`
+	Type        int    `json:"type"`
+}
+
+type SuggestedApp struct {
+	Apps_Name     string `json:"apps_name"`
+	Members_Count int    `json:"members_count"`
+}
+
+// search/suggestions/at_users返回的@联想用户
+type AtUser struct {
+	Uid      ID     `json:"uid"`
+	Nickname string `json:"nickname"`
+	Remark   string `json:"remark,omitempty"`
+}
+
+// suggestions/users/may_interested返回的可能感兴趣的用户
+type InterestedUser struct {
+	Uid    ID              `json:"uid"`
+	Reason json.RawMessage `json:"reason,omitempty"` // 推荐理由，比如共同关注的人，格式随推荐类型变化，因此保留原始JSON
 }
 
 type Visible struct {
-	Type    int
-	List_Id int
+	Type    int `json:"type"`
+	List_Id ID  `json:"list_id"`
 }
 
 type Pic_Url struct {
-	Thumbnail_Pic string
+	Thumbnail_Pic string `json:"thumbnail_pic"`
+}
+
+type UploadedPic struct {
+	Pic_Id        string `json:"pic_id"`
+	Thumbnail_Pic string `json:"thumbnail_pic"`
+	Bmiddle_Pic   string `json:"bmiddle_pic"`
+	Original_Pic  string `json:"original_pic"`
 }
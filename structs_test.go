@@ -0,0 +1,136 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testdata中的API返回样本，解析后再编码应当和样本完全一致
+var roundTripSamples = []struct {
+	file     string
+	newValue func() interface{}
+}{
+	{"status.json", func() interface{} { return new(Status) }},
+	{"user.json", func() interface{} { return new(User) }},
+	{"comment.json", func() interface{} { return new(Comment) }},
+	{"access_token.json", func() interface{} { return new(AccessToken) }},
+	{"access_token_info.json", func() interface{} { return new(AccessTokenInfo) }},
+}
+
+func TestStructsRoundTrip(t *testing.T) {
+	for _, sample := range roundTripSamples {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", sample.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := sample.newValue()
+		if err := json.Unmarshal(data, value); err != nil {
+			t.Errorf("%s：解析失败：%v", sample.file, err)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			t.Errorf("%s：编码失败：%v", sample.file, err)
+			continue
+		}
+
+		if diff := jsonDiff("", decodeJSON(t, data), decodeJSON(t, encoded)); len(diff) > 0 {
+			t.Errorf("%s：编码后和样本不同\n\t%s", sample.file, joinLines(diff))
+		}
+
+		// 编码后的JSON再解析、编码一次应当完全不变
+		again := sample.newValue()
+		if err := json.Unmarshal(encoded, again); err != nil {
+			t.Errorf("%s：再次解析失败：%v", sample.file, err)
+			continue
+		}
+		reencoded, err := json.Marshal(again)
+		if err != nil {
+			t.Errorf("%s：再次编码失败：%v", sample.file, err)
+			continue
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Errorf("%s：再次编码的结果不同\n%s\n%s", sample.file, encoded, reencoded)
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, data []byte) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// 比较两个解析后的JSON值，返回按路径排序的不同之处
+func jsonDiff(path string, expected, actual interface{}) []string {
+	diff := make([]string, 0)
+	expectedMap, ok1 := expected.(map[string]interface{})
+	actualMap, ok2 := actual.(map[string]interface{})
+	if ok1 && ok2 {
+		keys := make(map[string]bool)
+		for key := range expectedMap {
+			keys[key] = true
+		}
+		for key := range actualMap {
+			keys[key] = true
+		}
+		for key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			e, inExpected := expectedMap[key]
+			a, inActual := actualMap[key]
+			switch {
+			case !inActual:
+				diff = append(diff, fmt.Sprintf("%s: %s → 缺失", keyPath, formatJSON(e)))
+			case !inExpected:
+				diff = append(diff, fmt.Sprintf("%s: 缺失 → %s", keyPath, formatJSON(a)))
+			default:
+				diff = append(diff, jsonDiff(keyPath, e, a)...)
+			}
+		}
+		sort.Strings(diff)
+		return diff
+	}
+
+	expectedSlice, ok1 := expected.([]interface{})
+	actualSlice, ok2 := actual.([]interface{})
+	if ok1 && ok2 && len(expectedSlice) == len(actualSlice) {
+		for i := range expectedSlice {
+			diff = append(diff, jsonDiff(fmt.Sprintf("%s[%d]", path, i), expectedSlice[i], actualSlice[i])...)
+		}
+		return diff
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		diff = append(diff, fmt.Sprintf("%s: %s → %s", path, formatJSON(expected), formatJSON(actual)))
+	}
+	return diff
+}
+
+func formatJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func joinLines(lines []string) string {
+	var buffer bytes.Buffer
+	for i, line := range lines {
+		if i > 0 {
+			buffer.WriteString("\n\t")
+		}
+		buffer.WriteString(line)
+	}
+	return buffer.String()
+}
@@ -0,0 +1,258 @@
+// text包提供了微博正文的解析功能
+//
+// 微博正文（Status.Text）是纯文本，其中夹杂着@提及、#话题#、短链接和[表情]。
+// 本包将正文切分为带类型的实体，实体的位置以rune（而不是字节）为单位计算，
+// 这和微博自身对中文字符的处理方式一致。
+package text
+
+import (
+	"unicode"
+)
+
+// 实体类型
+type EntityType int
+
+const (
+	Plain    EntityType = iota // 普通文本，仅出现在Tokenize的输出中
+	Mention                    // @提及，比如 "@人民日报"
+	Hashtag                    // 话题，比如 "#新闻#"
+	URL                        // 链接，比如 "http://t.cn/RxLz2hE"
+	Emoticon                   // 表情，比如 "[哈哈]"
+)
+
+func (t EntityType) String() string {
+	switch t {
+	case Plain:
+		return "plain"
+	case Mention:
+		return "mention"
+	case Hashtag:
+		return "hashtag"
+	case URL:
+		return "url"
+	case Emoticon:
+		return "emoticon"
+	}
+	return "unknown"
+}
+
+// Entity结构体表示正文中的一个实体
+type Entity struct {
+	Type  EntityType
+	Start int    // 实体在正文中的起始位置（rune偏移，包含）
+	End   int    // 实体在正文中的结束位置（rune偏移，不包含）
+	Text  string // 实体在正文中的原始文本，比如 "@人民日报"
+	Value string // 实体的值：用户昵称、话题名或者表情名不含@、#和[]，链接则和Text相同
+}
+
+// 微博对实体长度的限制
+const (
+	maxMentionLength  = 30 // 昵称最长30个字符
+	maxHashtagLength  = 64 // 话题最长64个字符
+	maxEmoticonLength = 8  // 表情名最长8个字符
+)
+
+// 从正文中提取所有的实体，按照出现顺序返回
+func Parse(s string) []Entity {
+	entities := make([]Entity, 0)
+	for _, token := range Tokenize(s) {
+		if token.Type != Plain {
+			entities = append(entities, token)
+		}
+	}
+	return entities
+}
+
+// 将正文切分为连续的实体，实体之间的文本以Plain类型返回
+//
+// 所有输出实体的Text拼接起来等于输入的正文。
+func Tokenize(s string) []Entity {
+	runes := []rune(s)
+	tokens := make([]Entity, 0)
+	plainStart := 0
+	for i := 0; i < len(runes); {
+		entity, ok := matchEntity(runes, i)
+		if !ok {
+			i++
+			continue
+		}
+
+		// 输出实体之前的普通文本
+		if plainStart < i {
+			tokens = append(tokens, newEntity(Plain, runes, plainStart, i, plainStart, i))
+		}
+		tokens = append(tokens, entity)
+		i = entity.End
+		plainStart = i
+	}
+	if plainStart < len(runes) {
+		tokens = append(tokens, newEntity(Plain, runes, plainStart, len(runes), plainStart, len(runes)))
+	}
+	return tokens
+}
+
+// 尝试从位置i开始匹配一个实体
+func matchEntity(runes []rune, i int) (Entity, bool) {
+	switch runes[i] {
+	case '@', '＠':
+		return matchMention(runes, i)
+	case '#', '＃':
+		return matchHashtag(runes, i)
+	case '[':
+		return matchEmoticon(runes, i)
+	case 'h', 'H', 't', 'T':
+		return matchURL(runes, i)
+	}
+	return Entity{}, false
+}
+
+// 匹配@提及
+//
+// 昵称由中日韩文字、英文字母、数字、下划线和减号组成，遇到其它字符（空白、标点、冒号、另一个@等）即结束。
+// 当@前面是英文字母或数字时（比如电子邮件地址）不视为提及。
+func matchMention(runes []rune, i int) (Entity, bool) {
+	if i > 0 && isASCIIAlnum(runes[i-1]) {
+		return Entity{}, false
+	}
+	end := i + 1
+	for end < len(runes) && end-i-1 < maxMentionLength && isNicknameRune(runes[end]) {
+		end++
+	}
+	if end == i+1 {
+		return Entity{}, false
+	}
+	return newEntity(Mention, runes, i, end, i+1, end), true
+}
+
+// 匹配#话题#
+//
+// 话题必须在同一行内闭合，不能为空，也不能只包含空白。
+func matchHashtag(runes []rune, i int) (Entity, bool) {
+	for end := i + 1; end < len(runes) && end-i-1 <= maxHashtagLength; end++ {
+		switch runes[end] {
+		case '\n', '\r':
+			return Entity{}, false
+		case '#', '＃':
+			if end == i+1 || isBlank(runes[i+1:end]) {
+				return Entity{}, false
+			}
+			return newEntity(Hashtag, runes, i, end+1, i+1, end), true
+		}
+	}
+	return Entity{}, false
+}
+
+// 匹配[表情]
+//
+// 表情名由中日韩文字、英文字母和数字组成，最长8个字符。
+func matchEmoticon(runes []rune, i int) (Entity, bool) {
+	for end := i + 1; end < len(runes) && end-i-1 <= maxEmoticonLength; end++ {
+		r := runes[end]
+		if r == ']' {
+			if end == i+1 {
+				return Entity{}, false
+			}
+			return newEntity(Emoticon, runes, i, end+1, i+1, end), true
+		}
+		if !isCJK(r) && !isASCIIAlnum(r) {
+			return Entity{}, false
+		}
+	}
+	return Entity{}, false
+}
+
+// 匹配链接，支持http(s)://开头的链接和不带协议的t.cn短链接
+func matchURL(runes []rune, i int) (Entity, bool) {
+	if i > 0 && isASCIIAlnum(runes[i-1]) {
+		return Entity{}, false
+	}
+	var prefixLength int
+	switch {
+	case hasPrefixFold(runes[i:], "https://"):
+		prefixLength = len("https://")
+	case hasPrefixFold(runes[i:], "http://"):
+		prefixLength = len("http://")
+	case hasPrefixFold(runes[i:], "t.cn/"):
+		prefixLength = len("t.cn/")
+	default:
+		return Entity{}, false
+	}
+
+	end := i + prefixLength
+	for end < len(runes) && isURLRune(runes[end]) {
+		end++
+	}
+
+	// 链接末尾的标点通常属于句子而不是链接
+	for end > i+prefixLength && isTrailingPunct(runes[end-1]) {
+		end--
+	}
+	if end == i+prefixLength {
+		return Entity{}, false
+	}
+	return newEntity(URL, runes, i, end, i, end), true
+}
+
+func newEntity(t EntityType, runes []rune, start, end, valueStart, valueEnd int) Entity {
+	return Entity{
+		Type:  t,
+		Start: start,
+		End:   end,
+		Text:  string(runes[start:end]),
+		Value: string(runes[valueStart:valueEnd]),
+	}
+}
+
+func hasPrefixFold(runes []rune, prefix string) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		if unicode.ToLower(runes[i]) != rune(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// 判断是否为中日韩文字（不包括全角标点）
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+func isNicknameRune(r rune) bool {
+	return isCJK(r) || isASCIIAlnum(r) || r == '_' || r == '-'
+}
+
+func isURLRune(r rune) bool {
+	if isASCIIAlnum(r) {
+		return true
+	}
+	switch r {
+	case '-', '.', '_', '~', ':', '/', '?', '!', '$', '&', '\'', '*', '+', ',', ';', '=', '%':
+		return true
+	}
+	return false
+}
+
+func isTrailingPunct(r rune) bool {
+	switch r {
+	case '.', ',', ';', ':', '!', '?', '\'':
+		return true
+	}
+	return false
+}
+
+func isBlank(runes []rune) bool {
+	for _, r := range runes {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
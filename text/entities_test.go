@@ -0,0 +1,102 @@
+package text
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试中使用的正文，覆盖所有实体类型以及容易出错的边界
+var tokenizeSamples = []string{
+	"",
+	"没有实体的纯文本",
+	"转发@张三：#话题# 看[哈哈]http://t.cn/RxLz2hE。",
+	"@人民日报@新华社 一起",
+	"回复@user_1-a:你好",
+	"邮件 a@b.com 不是提及",
+	"# # 不是话题",
+	"## 不是话题",
+	"#跨\n行# 不是话题",
+	"[abc [不闭合 [太长的表情名字超过八个]",
+	"链接结尾的标点不属于链接：https://example.com/a?b=1&c=2.",
+	"t.cn/abc 不带协议的短链接，xt.cn/abc 不是",
+	"全角＠提及和＃话题＃",
+}
+
+func TestTokenizeConcatenation(t *testing.T) {
+	for _, s := range tokenizeSamples {
+		tokens := Tokenize(s)
+		var builder strings.Builder
+		position := 0
+		for _, token := range tokens {
+			if token.Start != position {
+				t.Errorf("Tokenize(%q)：实体%q的Start = %d，期望 %d", s, token.Text, token.Start, position)
+			}
+			if token.End-token.Start != len([]rune(token.Text)) {
+				t.Errorf("Tokenize(%q)：实体%q的位置[%d, %d)和长度不一致", s, token.Text, token.Start, token.End)
+			}
+			builder.WriteString(token.Text)
+			position = token.End
+		}
+		if builder.String() != s {
+			t.Errorf("Tokenize(%q)拼接后为 %q", s, builder.String())
+		}
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i].Type == Plain && tokens[i-1].Type == Plain {
+				t.Errorf("Tokenize(%q)：相邻的普通文本%q和%q没有合并", s, tokens[i-1].Text, tokens[i].Text)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		text     string
+		entities []Entity
+	}{
+		{"转发@张三：#话题# 看[哈哈]http://t.cn/RxLz2hE。", []Entity{
+			{Mention, 2, 5, "@张三", "张三"},
+			{Hashtag, 6, 10, "#话题#", "话题"},
+			{Emoticon, 12, 16, "[哈哈]", "哈哈"},
+			{URL, 16, 35, "http://t.cn/RxLz2hE", "http://t.cn/RxLz2hE"},
+		}},
+		// 昵称遇到另一个@、空白或者标点时结束
+		{"@人民日报@新华社 一起", []Entity{
+			{Mention, 0, 5, "@人民日报", "人民日报"},
+			{Mention, 5, 9, "@新华社", "新华社"},
+		}},
+		{"回复@user_1-a:你好", []Entity{
+			{Mention, 2, 11, "@user_1-a", "user_1-a"},
+		}},
+		// 昵称中的中文和英文之间没有边界
+		{"@小明abc你好", []Entity{
+			{Mention, 0, 8, "@小明abc你好", "小明abc你好"},
+		}},
+		{"邮件 a@b.com 不是提及", []Entity{}},
+		{"# # 不是话题", []Entity{}},
+		{"## 不是话题", []Entity{}},
+		{"#跨\n行# 不是话题", []Entity{}},
+		{"[abc [不闭合 [太长的表情名字超过八个]", []Entity{}},
+		{"链接结尾的标点不属于链接：https://example.com/a?b=1&c=2.", []Entity{
+			{URL, 13, 42, "https://example.com/a?b=1&c=2", "https://example.com/a?b=1&c=2"},
+		}},
+		{"t.cn/abc 不带协议的短链接，xt.cn/abc 不是", []Entity{
+			{URL, 0, 8, "t.cn/abc", "t.cn/abc"},
+		}},
+		// 域名不区分大小写
+		{"看T.cn/RxLz2hE和HTTP://T.CN/abc", []Entity{
+			{URL, 1, 13, "T.cn/RxLz2hE", "T.cn/RxLz2hE"},
+			{URL, 14, 29, "HTTP://T.CN/abc", "HTTP://T.CN/abc"},
+		}},
+		{"全角＠提及和＃话题＃", []Entity{
+			{Mention, 2, 6, "＠提及和", "提及和"},
+			{Hashtag, 6, 10, "＃话题＃", "话题"},
+		}},
+	}
+	for _, c := range cases {
+		entities := Parse(c.text)
+		if !reflect.DeepEqual(entities, c.entities) {
+			t.Errorf("Parse(%q) = %+v，期望 %+v", c.text, entities, c.entities)
+		}
+	}
+}
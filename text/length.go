@@ -0,0 +1,114 @@
+package text
+
+import (
+	"strings"
+)
+
+// 微博字数计算相关的常数
+//
+// 微博按照"半角单位"计算长度：ASCII字符计1个单位，其它字符（包括中文和全角标点）计2个单位，
+// 总字数为单位数除以2向上取整。链接按照固定长度计算：t.cn短链接按照其实际长度计算，
+// 其它不超过140个单位的链接计20个单位（即10个字），更长的链接每超出1个单位多计1个单位。
+const (
+	urlUnits          = 20  // 一个链接计算的单位数
+	maxFixedURLUnits  = 140 // 超过此单位数的链接按照超出部分追加计算
+	shortURLPrefix    = "t.cn/"
+	shortURLPrefixLen = len(shortURLPrefix)
+)
+
+// 按照微博的规则计算正文的字数
+//
+// 中文等全角字符计1个字，英文字母、数字等半角字符计半个字，链接按照固定长度计算，结果向上取整。
+func Length(s string) int {
+	return (units(s) + 1) / 2
+}
+
+// 将正文截断到不超过limit个字（按照Length计算），截断时附加suffix（比如"…"）
+//
+// 截断不会发生在链接、@提及、#话题#和[表情]的中间，这些实体要么被完整保留，要么被整个去掉。
+// 当正文没有超过limit时原样返回。
+func Truncate(s string, limit int, suffix string) string {
+	if Length(s) <= limit {
+		return s
+	}
+
+	budget := limit*2 - units(suffix)
+	if budget < 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	used := 0
+	for _, token := range Tokenize(s) {
+		if token.Type != Plain {
+			// 实体不可分割
+			tokenCost := tokenUnits(token)
+			if used+tokenCost > budget {
+				break
+			}
+			builder.WriteString(token.Text)
+			used += tokenCost
+			continue
+		}
+
+		exhausted := false
+		for _, r := range token.Text {
+			runeCost := runeUnits(r)
+			if used+runeCost > budget {
+				exhausted = true
+				break
+			}
+			builder.WriteRune(r)
+			used += runeCost
+		}
+		if exhausted {
+			break
+		}
+	}
+	return strings.TrimRight(builder.String(), " \t\r\n") + suffix
+}
+
+// 计算字符串的半角单位数
+func units(s string) int {
+	total := 0
+	for _, token := range Tokenize(s) {
+		total += tokenUnits(token)
+	}
+	return total
+}
+
+func tokenUnits(token Entity) int {
+	if token.Type == URL {
+		return urlTokenUnits(token.Text)
+	}
+	total := 0
+	for _, r := range token.Text {
+		total += runeUnits(r)
+	}
+	return total
+}
+
+func urlTokenUnits(url string) int {
+	length := 0
+	for _, r := range url {
+		length += runeUnits(r)
+	}
+
+	// t.cn短链接按照实际长度计算
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(url), "http://"), "https://")
+	if len(trimmed) >= shortURLPrefixLen && trimmed[:shortURLPrefixLen] == shortURLPrefix {
+		return length
+	}
+
+	if length <= maxFixedURLUnits {
+		return urlUnits
+	}
+	return length - maxFixedURLUnits + urlUnits
+}
+
+func runeUnits(r rune) int {
+	if r < 0x80 {
+		return 1
+	}
+	return 2
+}
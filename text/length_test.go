@@ -0,0 +1,87 @@
+package text
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		text   string
+		length int
+	}{
+		{"", 0},
+		{"hello", 3},    // 5个半角单位
+		{"中文", 2},       // 4个半角单位
+		{"中a", 2},       // 3个半角单位，向上取整
+		{"，。！", 3},      // 全角标点计1个字
+		{"@人民日报 你好", 7}, // 1 + 8 + 1 + 4
+		{"看 http://example.com/a/very/long/path", 12},          // 2 + 1 + 20
+		{"http://t.cn/RxLz2hE", 10},                            // 短链接按照实际长度计算
+		{"t.cn/RxLz2hE", 6},                                    // 不带协议的短链接
+		{"http://example.com/" + strings.Repeat("a", 150), 25}, // 169个单位的链接计 169 - 140 + 20
+		{"[哈哈]#话题#", 6},
+	}
+	for _, c := range cases {
+		if length := Length(c.text); length != c.length {
+			t.Errorf("Length(%q) = %d，期望 %d", c.text, length, c.length)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		text   string
+		limit  int
+		suffix string
+		result string
+	}{
+		{"没有超过", 4, "…", "没有超过"},
+		{"一二三四五六七八", 4, "…", "一二三…"},
+		{"一二三四五六七八", 4, "", "一二三四"},
+		// 截断时去掉末尾的空白
+		{"一二 三四五六", 3, "", "一二"},
+		// 链接、@提及、#话题#和[表情]被整个去掉
+		{"你好http://example.com/path 再见", 5, "…", "你好…"},
+		{"abc @人民日报新闻 后面还有很多字", 6, "", "abc"},
+		// @前面是英文字母时不是提及，按照普通文本截断
+		{"abc@人民日报新闻 后面还有很多字", 6, "", "abc@人民日报"},
+		{"一二#很长的话题名称#三四", 5, "", "一二"},
+		{"一二[哈哈哈]三四", 4, "", "一二"},
+		{"一二[哈哈哈]三四", 6, "", "一二[哈哈哈]"},
+		// suffix比limit还长
+		{"一二三四五", 1, "……", ""},
+	}
+	for _, c := range cases {
+		if result := Truncate(c.text, c.limit, c.suffix); result != c.result {
+			t.Errorf("Truncate(%q, %d, %q) = %q，期望 %q", c.text, c.limit, c.suffix, result, c.result)
+		}
+	}
+}
+
+// 对所有可能的limit检查Truncate的结果不超过limit，并且不会截断在实体的中间
+func TestTruncateEntityBoundaries(t *testing.T) {
+	const suffix = "…"
+	s := "转发@人民日报新闻：#今日话题# 详见http://example.com/news/1234 和t.cn/RxLz2hE[哈哈]，完。"
+	entities := Parse(s)
+	for limit := 0; limit <= Length(s); limit++ {
+		result := Truncate(s, limit, suffix)
+		if Length(result) > limit {
+			t.Errorf("Truncate(s, %d)的结果%q超过了limit", limit, result)
+		}
+		if result == s || result == "" {
+			continue
+		}
+		kept := strings.TrimSuffix(result, suffix)
+		if !strings.HasPrefix(s, kept) {
+			t.Errorf("Truncate(s, %d)的结果%q不是正文的前缀", limit, result)
+			continue
+		}
+		end := len([]rune(kept))
+		for _, entity := range entities {
+			if entity.Start < end && end < entity.End {
+				t.Errorf("Truncate(s, %d)的结果%q截断在实体%q的中间", limit, result, entity.Text)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gobo
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Attributes类型表示span的属性
+type Attributes map[string]interface{}
+
+// Tracer接口用来接入外部的链路追踪系统
+//
+// gobo不依赖任何追踪SDK，使用者只需要实现这两个很小的接口，将调用转发给自己的追踪系统即可。
+type Tracer interface {
+	// 开始一个span，name比如 "gobo.request"，attrs为初始属性
+	StartSpan(name string, attrs Attributes) Span
+}
+
+// Span接口表示一个进行中的span
+type Span interface {
+	// 记录span中发生的事件，比如DNS解析完成
+	AddEvent(name string, attrs Attributes)
+
+	// 结束span，err为nil表示成功，attrs为结束时补充的属性
+	EndSpan(err error, attrs Attributes)
+}
+
+// 设置链路追踪，tracer为nil时不追踪（默认）
+//
+// 设置之后每一次HTTP请求（包括每一次重试和分页抓取的每一页）都产生一个名为 "gobo.request" 的span，
+// 其中记录了DNS解析、建立连接、TLS握手和收到首字节的事件和耗时；解析响应产生一个名为 "gobo.decode" 的span。
+// 该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) SetTracer(tracer Tracer) {
+	weibo.tracer = tracer
+}
+
+// 和分页相关的参数，记录在span的属性中
+var pagingParams = []string{"page", "count", "cursor", "since_id", "max_id"}
+
+// 开始一次HTTP请求的span，并在httpReq上安装httptrace，返回带有追踪的请求和结束span的函数
+func (weibo *Weibo) traceRequest(req *Request, httpReq *http.Request) (*http.Request, func(resp *Response, err error)) {
+	attrs := Attributes{
+		"endpoint": req.Endpoint,
+		"method":   req.HTTPMethod,
+		"attempt":  req.Attempt,
+	}
+	for _, name := range pagingParams {
+		if value, ok := req.Params[name]; ok {
+			attrs[name] = value
+		}
+	}
+	span := weibo.tracer.StartSpan("gobo.request", attrs)
+	timings := &requestTimings{span: span, start: time.Now(), summary: Attributes{}}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), timings.clientTrace()))
+
+	return httpReq, func(resp *Response, err error) {
+		endAttrs := Attributes{}
+		timings.mutex.Lock()
+		for k, v := range timings.summary {
+			endAttrs[k] = v
+		}
+		timings.mutex.Unlock()
+		endAttrs["duration"] = time.Since(timings.start)
+		if resp != nil {
+			endAttrs["status"] = resp.StatusCode
+			endAttrs["size"] = len(resp.Body)
+			if err == nil {
+				err = resp.APIError()
+			}
+		}
+		span.EndSpan(err, endAttrs)
+	}
+}
+
+// 开始解析响应的span，返回结束span的函数
+func (weibo *Weibo) traceDecode(req *Request) func(err error) {
+	if weibo.tracer == nil {
+		return func(error) {}
+	}
+	span := weibo.tracer.StartSpan("gobo.decode", Attributes{"endpoint": req.Endpoint})
+	start := time.Now()
+	return func(err error) {
+		span.EndSpan(err, Attributes{"duration": time.Since(start)})
+	}
+}
+
+// requestTimings记录一次HTTP请求各个阶段的耗时
+type requestTimings struct {
+	mutex     sync.Mutex
+	span      Span
+	start     time.Time
+	dnsStart  time.Time
+	connStart time.Time
+	tlsStart  time.Time
+	summary   Attributes // 各阶段的耗时等，在span结束时作为属性
+}
+
+func (t *requestTimings) event(name string, attrs Attributes) {
+	t.mutex.Lock()
+	for k, v := range attrs {
+		t.summary[k] = v
+	}
+	t.mutex.Unlock()
+	t.span.AddEvent(name, attrs)
+}
+
+func (t *requestTimings) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mutex.Lock()
+			t.dnsStart = time.Now()
+			t.mutex.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.mutex.Lock()
+			d := time.Since(t.dnsStart)
+			t.mutex.Unlock()
+			attrs := Attributes{"dns": d}
+			if info.Err != nil {
+				attrs["dns_error"] = info.Err.Error()
+			}
+			t.event("dns_done", attrs)
+		},
+		ConnectStart: func(network, addr string) {
+			t.mutex.Lock()
+			t.connStart = time.Now()
+			t.mutex.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mutex.Lock()
+			d := time.Since(t.connStart)
+			t.mutex.Unlock()
+			attrs := Attributes{"connect": d, "addr": addr}
+			if err != nil {
+				attrs["connect_error"] = err.Error()
+			}
+			t.event("connect_done", attrs)
+		},
+		TLSHandshakeStart: func() {
+			t.mutex.Lock()
+			t.tlsStart = time.Now()
+			t.mutex.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mutex.Lock()
+			d := time.Since(t.tlsStart)
+			t.mutex.Unlock()
+			attrs := Attributes{"tls": d}
+			if err != nil {
+				attrs["tls_error"] = err.Error()
+			}
+			t.event("tls_done", attrs)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.event("got_conn", Attributes{"reused": info.Reused})
+		},
+		GotFirstResponseByte: func() {
+			t.event("first_byte", Attributes{"first_byte": time.Since(t.start)})
+		},
+	}
+}
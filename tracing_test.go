@@ -0,0 +1,151 @@
+package gobo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// 记录所有span的Tracer
+type recordingTracer struct {
+	mutex sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	tracer *recordingTracer
+	name   string
+	attrs  Attributes
+	events []string
+	err    error
+	ended  bool
+}
+
+func (tracer *recordingTracer) StartSpan(name string, attrs Attributes) Span {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	span := &recordedSpan{tracer: tracer, name: name, attrs: Attributes{}}
+	for k, v := range attrs {
+		span.attrs[k] = v
+	}
+	tracer.spans = append(tracer.spans, span)
+	return span
+}
+
+func (span *recordedSpan) AddEvent(name string, attrs Attributes) {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	span.events = append(span.events, name)
+}
+
+func (span *recordedSpan) EndSpan(err error, attrs Attributes) {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	for k, v := range attrs {
+		span.attrs[k] = v
+	}
+	span.err = err
+	span.ended = true
+}
+
+// 并发请求时每个请求产生一个gobo.request和一个gobo.decode span，其中记录了连接、TLS握手等事件
+func TestTracer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"target weibo does not exist!","error_code":20101,"request":"/2/statuses/show.json"}`)
+			return
+		}
+		fmt.Fprint(w, `{"statuses":[]}`)
+	}))
+	defer server.Close()
+
+	var tracer recordingTracer
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	weibo.SetHTTPClient(server.Client())
+	weibo.SetTracer(&tracer)
+
+	const n = 10
+	var wg sync.WaitGroup
+	for page := 0; page < n; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			err := weibo.Call("statuses/user_timeline", "get", "token", Params{"page": page}, &Statuses{})
+			if (err != nil) != (page == 0) {
+				t.Errorf("第%d页返回 %v", page, err)
+			}
+		}(page)
+	}
+	wg.Wait()
+
+	requests := make(map[interface{}]*recordedSpan)
+	decodes, decodeErrors := 0, 0
+	for _, span := range tracer.spans {
+		if !span.ended {
+			t.Errorf("span %s没有结束", span.name)
+		}
+		switch span.name {
+		case "gobo.request":
+			requests[span.attrs["page"]] = span
+		case "gobo.decode":
+			decodes++
+			if span.err != nil {
+				decodeErrors++
+			}
+		default:
+			t.Errorf("未知的span %s", span.name)
+		}
+	}
+	// 返回微博错误的请求在解析响应时得到该错误
+	if len(requests) != n || decodes != n || decodeErrors != 1 {
+		t.Fatalf("得到%d个gobo.request和%d个gobo.decode（其中%d个出错），期望%d个、%d个和1个", len(requests), decodes, decodeErrors, n, n)
+	}
+
+	for page := 0; page < n; page++ {
+		span := requests[page]
+		if span == nil {
+			t.Errorf("第%d页没有gobo.request", page)
+			continue
+		}
+		if span.attrs["endpoint"] != "statuses/user_timeline" || span.attrs["method"] != "get" || span.attrs["duration"] == nil {
+			t.Errorf("第%d页的属性为 %v", page, span.attrs)
+		}
+		if events := fmt.Sprint(span.events); !contains(span.events, "got_conn") || !contains(span.events, "first_byte") {
+			t.Errorf("第%d页的事件为 %s", page, events)
+		}
+		if page == 0 {
+			if weiboErr, ok := span.err.(WeiboError); !ok || weiboErr.Error_Code != 20101 || span.attrs["status"] != 400 {
+				t.Errorf("返回错误的请求的span为 %v %v", span.err, span.attrs)
+			}
+		} else if span.err != nil || span.attrs["status"] != 200 {
+			t.Errorf("第%d页的span为 %v %v", page, span.err, span.attrs)
+		}
+	}
+
+	// 至少有一个请求建立了新的TLS连接
+	handshakes := 0
+	for _, span := range requests {
+		if contains(span.events, "tls_done") {
+			handshakes++
+			if span.attrs["tls"] == nil || span.attrs["connect"] == nil {
+				t.Errorf("建立连接的请求没有记录耗时：%v", span.attrs)
+			}
+		}
+	}
+	if handshakes == 0 {
+		t.Error("没有记录TLS握手")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
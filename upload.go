@@ -0,0 +1,163 @@
+package gobo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Image结构体表示一张待上传的图片
+type Image struct {
+	Reader   io.Reader    // 包含图片的二进制流
+	Format   string       // 图片的格式，实际的格式根据图片内容识别，可以为空
+	Progress ProgressFunc // 上传进度回调函数，可以为nil
+}
+
+// UploadError在UploadPics部分图片上传失败时返回
+type UploadError struct {
+	PicIds []string // 和输入图片一一对应，上传失败的图片为空字符串
+	Errors []error  // 和输入图片一一对应，上传成功的图片为nil
+}
+
+func (e *UploadError) Error() string {
+	failed := make([]string, 0)
+	for i, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("第%d张：%v", i+1, err))
+		}
+	}
+	return "Gobo错误：图片上传失败 " + strings.Join(failed, "；")
+}
+
+// 调用/statuses/upload_pic上传一张图片，返回图片的pic_id
+//
+// 输入参数
+//
+//	token		用户授权的访问令牌
+//	reader		包含图片的二进制流
+//	imageFormat	图片的格式，比如 "jpg" 又如 "png"。实际的格式根据图片内容识别，此参数仅为兼容而保留
+//
+// 得到的pic_id可以用于UploadPics或者statuses/upload_url_text的pic_id参数。
+func (weibo *Weibo) UploadPic(token string, reader io.Reader, imageFormat string) (string, error) {
+	return weibo.uploadPic(token, reader, imageFormat, nil)
+}
+
+func (weibo *Weibo) uploadPic(token string, reader io.Reader, imageFormat string, progress ProgressFunc) (string, error) {
+	reader, err := weibo.preprocessImage(reader)
+	if err != nil {
+		return "", err
+	}
+	req := &Request{
+		Endpoint:   UploadPicAPIName,
+		HTTPMethod: "post",
+		Token:      token,
+		Upload:     &Upload{Reader: reader, Progress: progress},
+	}
+	var pic UploadedPic
+	err = weibo.do(req, &pic)
+	if err != nil {
+		return "", err
+	}
+	if pic.Pic_Id == "" {
+		return "", &ErrorString{"上传图片未返回pic_id"}
+	}
+	return pic.Pic_Id, nil
+}
+
+// 发带多张图片的微博
+//
+// 输入参数
+//
+//	token		用户授权的访问令牌
+//	params		JSON输入参数，见Params结构体的注释，不应当包含pic_id参数
+//	images		待上传的图片，最多MaxPicsPerStatus张，微博中图片的顺序和images的顺序一致
+//	response	API服务器的JSON输出将被还原成该结构体
+//
+// 所有图片通过/statuses/upload_pic并行上传，全部成功后通过/statuses/upload_url_text的pic_id参数发布。
+// 当部分图片上传失败时不发布微博，返回*UploadError，其中包含每张图片的pic_id和错误。
+func (weibo *Weibo) UploadPics(token string, params Params, images []Image, response interface{}) error {
+	if len(images) == 0 {
+		return &ErrorString{"至少需要一张图片"}
+	}
+	if len(images) > MaxPicsPerStatus {
+		return &ErrorString{fmt.Sprintf("一条微博最多包含%d张图片", MaxPicsPerStatus)}
+	}
+	if err := params.Validate(UploadUrlTextAPIName); err != nil {
+		return err
+	}
+
+	// 并行上传，结果按照输入顺序保存
+	uploadErr := &UploadError{
+		PicIds: make([]string, len(images)),
+		Errors: make([]error, len(images)),
+	}
+	var wg sync.WaitGroup
+	for i, image := range images {
+		wg.Add(1)
+		go func(i int, image Image) {
+			defer wg.Done()
+			uploadErr.PicIds[i], uploadErr.Errors[i] = weibo.uploadPic(token, image.Reader, image.Format, image.Progress)
+		}(i, image)
+	}
+	wg.Wait()
+	for _, err := range uploadErr.Errors {
+		if err != nil {
+			return uploadErr
+		}
+	}
+
+	// 发布微博，不修改调用者的params
+	statusParams := Params{}
+	for k, v := range params {
+		statusParams[k] = v
+	}
+	statusParams["pic_id"] = strings.Join(uploadErr.PicIds, ",")
+	return weibo.Call(UploadUrlTextAPIName, "post", token, statusParams, response)
+}
+
+// 应用没有调用某个API的权限时微博返回的错误代码
+const errorCodeInsufficientAppPermissions = 10014
+
+// 调用/statuses/upload_url_text发带网络图片的微博
+//
+// 输入参数
+//
+//	token		用户授权的访问令牌
+//	params		JSON输入参数，见Params结构体的注释，不应当包含url参数
+//	picUrl		图片的URL，由微博服务器下载
+//	fallback	为真时，如果应用没有upload_url_text的权限，则在本地下载图片后通过Upload函数发布
+//	response	API服务器的JSON输出将被还原成该结构体
+//
+// 当出现异常时输出非nil错误
+func (weibo *Weibo) UploadUrlText(token string, params Params, picUrl string, fallback bool, response interface{}) error {
+	if picUrl == "" {
+		return &ErrorString{"图片URL不能为空"}
+	}
+
+	// 不修改调用者的params
+	urlParams := Params{}
+	for k, v := range params {
+		urlParams[k] = v
+	}
+	urlParams["url"] = picUrl
+	err := weibo.Call(UploadUrlTextAPIName, "post", token, urlParams, response)
+	if err == nil || !fallback {
+		return err
+	}
+	weiboErr, ok := err.(WeiboError)
+	if !ok || weiboErr.Error_Code != errorCodeInsufficientAppPermissions {
+		return err
+	}
+
+	// 没有权限时在本地下载图片然后上传
+	resp, err := weibo.httpClient.Get(picUrl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return &ErrorString{fmt.Sprintf("下载图片失败，HTTP状态码%d", resp.StatusCode)}
+	}
+	return weibo.Upload(token, params, resp.Body, "", response)
+}
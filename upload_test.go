@@ -0,0 +1,205 @@
+package gobo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 生成宽度为width的PNG图片，模拟服务器根据宽度区分图片
+func testImage(t *testing.T, width int) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewGray(image.Rect(0, 0, width, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// 并行上传的图片按照输入顺序发布；部分图片上传失败时不发布微博，UploadError中包含每张图片的结果
+func TestUploadPics(t *testing.T) {
+	var mutex sync.Mutex
+	published := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2/statuses/upload_pic.json":
+			file, _, err := r.FormFile("pic")
+			if err != nil {
+				t.Errorf("upload_pic没有图片：%v", err)
+				return
+			}
+			config, err := png.DecodeConfig(file)
+			if err != nil {
+				t.Errorf("无法解析上传的图片：%v", err)
+				return
+			}
+			// 打乱完成的顺序
+			time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+			if config.Width == 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"image size too large","error_code":20006,"request":"/2/statuses/upload_pic.json"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"pic_id":"pic%d"}`, config.Width)
+		case "/2/statuses/upload_url_text.json":
+			mutex.Lock()
+			published = append(published, r.FormValue("pic_id"))
+			mutex.Unlock()
+			fmt.Fprintf(w, `{"id":1,"text":"%s"}`, r.FormValue("status"))
+		default:
+			t.Errorf("未知的请求%s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	images := func(widths ...int) []Image {
+		result := make([]Image, len(widths))
+		for i, width := range widths {
+			result[i] = Image{Reader: bytes.NewReader(testImage(t, width))}
+		}
+		return result
+	}
+
+	var status Status
+	if err := weibo.UploadPics("token", Params{"status": "九张图"}, images(9, 8, 7, 6, 5, 4, 2, 1, 10), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Text != "九张图" || len(published) != 1 || published[0] != "pic9,pic8,pic7,pic6,pic5,pic4,pic2,pic1,pic10" {
+		t.Fatalf("发布了 %v，得到 %+v", published, status)
+	}
+
+	// 第二张图片上传失败
+	err := weibo.UploadPics("token", Params{"status": "失败"}, images(1, 3, 2), &status)
+	uploadErr, ok := err.(*UploadError)
+	if !ok {
+		t.Fatalf("部分图片上传失败时返回 %v，期望*UploadError", err)
+	}
+	if fmt.Sprint(uploadErr.PicIds) != "[pic1  pic2]" {
+		t.Errorf("PicIds = %q", uploadErr.PicIds)
+	}
+	if uploadErr.Errors[0] != nil || uploadErr.Errors[2] != nil {
+		t.Errorf("上传成功的图片有错误：%v", uploadErr.Errors)
+	}
+	if weiboErr, ok := uploadErr.Errors[1].(WeiboError); !ok || weiboErr.Error_Code != 20006 {
+		t.Errorf("第二张图片的错误为 %v", uploadErr.Errors[1])
+	}
+	if expected := "Gobo错误：图片上传失败 第2张：" + uploadErr.Errors[1].Error(); err.Error() != expected {
+		t.Errorf("Error() = %q，期望 %q", err.Error(), expected)
+	}
+	if len(published) != 1 {
+		t.Errorf("部分图片上传失败时不应当发布微博：%v", published)
+	}
+
+	// 图片数量不合法时不上传
+	for _, n := range []int{0, MaxPicsPerStatus + 1} {
+		widths := make([]int, n)
+		for i := range widths {
+			widths[i] = 1
+		}
+		if err := weibo.UploadPics("token", Params{"status": "图"}, images(widths...), &status); err == nil {
+			t.Errorf("%d张图片时应当返回错误", n)
+		}
+	}
+}
+
+// 应用没有upload_url_text权限（错误10014）时，fallback为真则在本地下载图片后通过statuses/upload发布
+func TestUploadUrlText(t *testing.T) {
+	pic := testImage(t, 5)
+	var mutex sync.Mutex
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, r.URL.Path)
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/pic.png":
+			w.Write(pic)
+		case "/2/statuses/upload_url_text.json":
+			switch r.FormValue("access_token") {
+			case "limited":
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":"Insufficient app permissions!","error_code":10014,"request":"/2/statuses/upload_url_text.json"}`)
+			case "repeated":
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"repeat content!","error_code":20019,"request":"/2/statuses/upload_url_text.json"}`)
+			default:
+				fmt.Fprintf(w, `{"id":1,"text":"%s"}`, r.FormValue("status"))
+			}
+		case "/2/statuses/upload.json":
+			file, _, err := r.FormFile("pic")
+			if err != nil {
+				t.Errorf("statuses/upload没有图片：%v", err)
+				return
+			}
+			if uploaded, _ := ioutil.ReadAll(file); !bytes.Equal(uploaded, pic) {
+				t.Error("statuses/upload收到的图片和下载的不一致")
+			}
+			if r.FormValue("url") != "" {
+				t.Errorf("statuses/upload不应当有url参数：%s", r.FormValue("url"))
+			}
+			fmt.Fprintf(w, `{"id":2,"text":"%s"}`, r.FormValue("status"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var weibo Weibo
+	weibo.SetBaseURL(server.URL)
+	picUrl := server.URL + "/pic.png"
+	for _, c := range []struct {
+		token    string
+		picUrl   string
+		fallback bool
+		id       ID    // 成功时微博的id
+		code     int64 // 微博错误代码
+		requests string
+	}{
+		{"full", picUrl, true, 1, 0, "[/2/statuses/upload_url_text.json]"},
+		{"limited", picUrl, false, 0, 10014, "[/2/statuses/upload_url_text.json]"},
+		{"limited", picUrl, true, 2, 0, "[/2/statuses/upload_url_text.json /pic.png /2/statuses/upload.json]"},
+		// 其它错误不使用fallback
+		{"repeated", picUrl, true, 0, 20019, "[/2/statuses/upload_url_text.json]"},
+		// 下载图片失败
+		{"limited", server.URL + "/missing.png", true, 0, 0, "[/2/statuses/upload_url_text.json /missing.png]"},
+	} {
+		requests = requests[:0]
+		params := Params{"status": "网络图片"}
+		var status Status
+		err := weibo.UploadUrlText(c.token, params, c.picUrl, c.fallback, &status)
+		name := fmt.Sprintf("%s %s fallback=%v", c.token, c.picUrl, c.fallback)
+		switch {
+		case c.id != 0:
+			if err != nil || status.Id != c.id || status.Text != "网络图片" {
+				t.Errorf("%s：得到 %+v %v", name, status, err)
+			}
+		case c.code != 0:
+			if weiboErr, ok := err.(WeiboError); !ok || weiboErr.Error_Code != c.code {
+				t.Errorf("%s：返回 %v，期望错误%d", name, err, c.code)
+			}
+		default:
+			if err == nil {
+				t.Errorf("%s：下载图片失败时应当返回错误", name)
+			}
+		}
+		if fmt.Sprint(requests) != c.requests {
+			t.Errorf("%s：服务器收到的请求为%v，期望%s", name, requests, c.requests)
+		}
+		if len(params) != 1 {
+			t.Errorf("%s：不应当修改调用者的params：%v", name, params)
+		}
+	}
+
+	if err := weibo.UploadUrlText("full", Params{"status": "图片"}, "", true, &Status{}); err == nil {
+		t.Error("URL为空时应当返回错误")
+	}
+}
@@ -0,0 +1,49 @@
+package gobo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/huichen/gobo/text"
+)
+
+// 需要检查字数的API及其正文参数
+var textParams = map[string]struct {
+	name     string // 正文的参数名
+	limit    int    // 字数上限
+	required bool   // 正文是否不能为空
+}{
+	"statuses/update":          {"status", MaxStatusLength, true},
+	"statuses/upload":          {"status", MaxStatusLength, true},
+	"statuses/upload_url_text": {"status", MaxStatusLength, true},
+	"statuses/share":           {"status", MaxStatusLength, true},
+	"statuses/repost":          {"status", MaxStatusLength, false},
+	"comments/create":          {"comment", MaxCommentLength, true},
+	"comments/reply":           {"comment", MaxCommentLength, true},
+}
+
+// 检查发微博、转发和评论等API的正文是否符合微博的字数限制
+//
+// method为API方法名，比如 "statuses/update"。对于不需要检查的API总是返回nil。
+// Call和Upload函数在发送请求之前会自动调用此函数，从而避免一次注定失败的API访问。
+func (params Params) Validate(method string) error {
+	rule, ok := textParams[strings.Trim(method, "/")]
+	if !ok {
+		return nil
+	}
+
+	var content string
+	if value, ok := params[rule.name]; ok && value != nil {
+		content = fmt.Sprint(value)
+	}
+	if content == "" {
+		if rule.required {
+			return &ErrorString{fmt.Sprintf("%s参数不能为空", rule.name)}
+		}
+		return nil
+	}
+	if length := text.Length(content); length > rule.limit {
+		return &ErrorString{fmt.Sprintf("%s参数长度为%d字，超过了%d字的限制", rule.name, length, rule.limit)}
+	}
+	return nil
+}
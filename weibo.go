@@ -6,19 +6,83 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Params类型用来表达微博API的JSON输入参数。注意：
 // 	1. Params不应当包含访问令牌(access_token)，因为它已经是Call和Upload函数的参数
 // 	2. 在Upload函数中，Params参数不应当包含pic参数，上传的图片内容和类型应当通过reader和imageFormat指定
+// 	3. 值为[]string或者[]ID时参数被重复发送，比如 Params{"url_long": []string{a, b}} 发送 url_long=a&url_long=b
 type Params map[string]interface{}
 
+// 将参数转换成url.Values，值通过fmt.Sprint转换成字符串，空的参数被忽略
+func (params Params) values() url.Values {
+	values := url.Values{}
+	for k, v := range params {
+		if k == "" {
+			continue
+		}
+		var strs []string
+		switch v := v.(type) {
+		case []string:
+			strs = v
+		case []ID:
+			strs = make([]string, len(v))
+			for i, id := range v {
+				strs[i] = id.String()
+			}
+		default:
+			strs = []string{fmt.Sprint(v)}
+		}
+		for _, value := range strs {
+			if value != "" {
+				values.Add(k, value)
+			}
+		}
+	}
+	return values
+}
+
 // Weibo结构体定义了微博API调用功能
 type Weibo struct {
-	httpClient http.Client
+	httpClient          http.Client
+	imageOptions        *ImageOptions
+	middlewares         []Middleware
+	tracer              Tracer
+	baseURL             string
+	longTextConcurrency int // 自动展开长微博的并发请求数，见SetExpandLongText
+}
+
+// 安装中间件，见Middleware类型的注释
+//
+// 中间件作用于Call、Upload等所有API调用，按照安装的顺序由外向内包装，即先安装的中间件先看到请求、后看到响应。
+// 该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) Use(middlewares ...Middleware) {
+	weibo.middlewares = append(weibo.middlewares, middlewares...)
+}
+
+// 设置发送请求使用的HTTP客户端，比如使用自定义的Transport、代理或者超时
+//
+// client被复制，之后对client的修改不会生效。该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) SetHTTPClient(client *http.Client) {
+	weibo.httpClient = *client
+}
+
+// 设置API服务器的地址，比如 "http://127.0.0.1:8080"，为空时使用ApiDomain（默认）
+//
+// 通常用于在测试中访问模拟的API服务器，见gobotest.NewServer。该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) SetBaseURL(baseURL string) {
+	weibo.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// 设置上传图片之前的预处理，options为nil时不做预处理（默认）
+//
+// 设置之后Upload、UploadPic和UploadPics函数在上传前会调用PreprocessImage处理图片。
+// 该函数应当在调用其它函数之前调用。
+func (weibo *Weibo) SetImageOptions(options *ImageOptions) {
+	weibo.imageOptions = options
 }
 
 // 调用微博API
@@ -32,15 +96,29 @@ type Weibo struct {
 //	params		JSON输入参数，见Params结构体的注释
 //	response	API服务器的JSON输出将被还原成该结构体
 //
+// 对于发微博、转发和评论等API，发送请求之前会检查正文字数，见Params.Validate函数。
+// 设置了SetExpandLongText时，时间线中被截断的长微博会被自动展开，展开失败的微博只有截断的Text，
+// 错误记录在Status.LongTextError中而不由Call返回。
+//
 // 当出现异常时输出非nil错误
 func (weibo *Weibo) Call(method string, httpMethod string, token string, params Params, response interface{}) error {
-	apiUri := fmt.Sprintf("%s/%s/%s%s", ApiDomain, ApiVersion, method, ApiNamePostfix)
-	if httpMethod == "get" {
-		return weibo.sendGetHttpRequest(apiUri, token, params, response)
-	} else if httpMethod == "post" {
-		return weibo.sendPostHttpRequest(apiUri, token, params, nil, "", response)
+	if httpMethod != "get" && httpMethod != "post" {
+		return &ErrorString{"HTTP方法只能是\"get\"或者\"post\""}
+	}
+	if err := params.Validate(method); err != nil {
+		return err
+	}
+	req := &Request{
+		Endpoint:   strings.Trim(method, "/"),
+		HTTPMethod: httpMethod,
+		Token:      token,
+		Params:     params,
+	}
+	if err := weibo.do(req, response); err != nil {
+		return err
 	}
-	return &ErrorString{"HTTP方法只能是\"get\"或者\"post\""}
+	weibo.expandTimeline(req.Endpoint, token, response)
+	return nil
 }
 
 // 调用/statuses/upload发带图片微博
@@ -49,125 +127,175 @@ func (weibo *Weibo) Call(method string, httpMethod string, token string, params
 //	token		用户授权的访问令牌
 //	params		JSON输入参数，见Params结构体的注释
 //	reader		包含图片的二进制流
-//	imageFormat	图片的格式，比如 "jpg" 又如 "png"
+//	imageFormat	图片的格式，比如 "jpg" 又如 "png"。实际的格式根据图片内容识别，此参数仅为兼容而保留
 //	response	API服务器的JSON输出将被还原成该结构体
 //
 // 当出现异常时输出非nil错误
 func (weibo *Weibo) Upload(token string, params Params, reader io.Reader, imageFormat string, response interface{}) error {
-	apiUri := fmt.Sprintf("%s/%s/%s%s", ApiDomain, ApiVersion, UploadAPIName, ApiNamePostfix)
-	return weibo.sendPostHttpRequest(apiUri, token, params, reader, imageFormat, response)
+	return weibo.UploadWithProgress(token, params, reader, imageFormat, nil, response)
 }
 
-// 向微博API服务器发送GET请求
-func (weibo *Weibo) sendGetHttpRequest(uri string, token string, params Params, response interface{}) error {
-	// 生成请求URI
-	var uriBuffer bytes.Buffer
-	uriBuffer.WriteString(fmt.Sprintf("%s?access_token=%s", uri, token))
-	for k, v := range params {
-		value := fmt.Sprint(v)
-		if k != "" && value != "" {
-			uriBuffer.WriteString(fmt.Sprintf("&%s=%s", k, value))
-		}
+// 调用/statuses/upload发带图片微博，并报告上传进度
+//
+// 输入参数
+//	token		用户授权的访问令牌
+//	params		JSON输入参数，见Params结构体的注释
+//	reader		包含图片的二进制流
+//	imageFormat	图片的格式，比如 "jpg" 又如 "png"。实际的格式根据图片内容识别，此参数仅为兼容而保留
+//	progress	上传进度回调函数，见ProgressFunc的注释，为nil时不报告进度
+//	response	API服务器的JSON输出将被还原成该结构体
+//
+// 图片内容不会被整体读入内存，而是边读边发送。当reader实现了Len() int方法（比如bytes.Reader）
+// 或者是可以Seek的文件时，请求带有Content-Length，否则使用chunked编码。读取reader出错时返回该错误。
+// 注意设置了SetImageOptions时，PreprocessImage会先将整张图片读入内存，处理之后再发送。
+// 图片格式不是JPEG、PNG或GIF时返回错误。
+//
+// 当出现异常时输出非nil错误
+func (weibo *Weibo) UploadWithProgress(token string, params Params, reader io.Reader, imageFormat string, progress ProgressFunc, response interface{}) error {
+	if err := params.Validate(UploadAPIName); err != nil {
+		return err
 	}
-	requestUri := uriBuffer.String()
-
-	// 发送GET请求
-	resp, err := weibo.httpClient.Get(requestUri)
+	reader, err := weibo.preprocessImage(reader)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	req := &Request{
+		Endpoint:   UploadAPIName,
+		HTTPMethod: "post",
+		Token:      token,
+		Params:     params,
+		Upload:     &Upload{Reader: reader, Progress: progress},
+	}
+	return weibo.do(req, response)
+}
 
-	// 解析API服务器返回内容
-	bytes, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode == 200 {
-		err := json.Unmarshal(bytes, &response)
-		if err != nil {
-			return err
-		}
-		return nil
-	} else {
-		var weiboErr WeiboError
-		err := json.Unmarshal(bytes, &weiboErr)
-		if err != nil {
-			return err
-		}
-		return weiboErr
+// 当设置了图片预处理时处理图片，否则原样返回
+func (weibo *Weibo) preprocessImage(reader io.Reader) (io.Reader, error) {
+	if weibo.imageOptions == nil {
+		return reader, nil
 	}
-	return nil
+	processed, _, err := PreprocessImage(reader, weibo.imageOptions)
+	if err != nil {
+		return nil, err
+	}
+	return processed, nil
 }
 
-// 向微博API服务器发送POST请求
+// 将API服务器返回的JSON还原到response中
 //
-// 输入参数的含义请见Upload函数注释。当reader == nil时使用query string模式，否则使用multipart。
-func (weibo *Weibo) sendPostHttpRequest(uri string, token string, params Params, reader io.Reader, imageFormat string, response interface{}) error {
-	// 生成POST请求URI
-	requestUri := fmt.Sprintf("%s?access_token=%s", uri, token)
-
-	// 生成POST内容
-	var bodyBuffer bytes.Buffer
-	var writer *multipart.Writer
-	if reader == nil {
-		// reader为nil时无文件上传，因此POST body为简单的query string模式
-		pb := url.Values{}
-		pb.Add("access_token", token)
-
-		for k, v := range params {
-			value := fmt.Sprint(v)
-			if k != "" && value != "" {
-				pb.Add(k, value)
-			}
-		}
-		bodyBuffer = *bytes.NewBufferString(pb.Encode())
-	} else {
-		// 否则POST body使用multipart模式
-		writer = multipart.NewWriter(&bodyBuffer)
-		imagePartWriter, _ := writer.CreateFormFile("pic", "image."+imageFormat)
-		io.Copy(imagePartWriter, reader)
-		for k, v := range params {
-			value := fmt.Sprint(v)
-			if k != "" && value != "" {
-				writer.WriteField(k, value)
-			}
-		}
-		writer.Close()
+// 解析时使用json.Number，当response中包含interface{}类型的字段时数字（比如各种ID）不会被截断为float64。
+func decodeResponse(data []byte, response interface{}) error {
+	if response == nil {
+		return nil
 	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(response)
+}
 
-	// 生成POST请求
-	req, err := http.NewRequest("POST", requestUri, &bodyBuffer)
+// 通过中间件发送请求，并将API服务器的响应还原到response中
+func (weibo *Weibo) do(req *Request, response interface{}) error {
+	resp, err := chainMiddlewares(weibo.middlewares, weibo.send)(req)
 	if err != nil {
 		return err
 	}
-	if reader == nil {
-		// reader为nil时使用一般的内容类型
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	} else {
-		// 否则使用带boundary的multipart类型
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+	endSpan := weibo.traceDecode(req)
+	err = resp.decode(response)
+	endSpan(err)
+	return err
+}
+
+// 向微博API服务器发送请求，位于中间件链的最内层
+func (weibo *Weibo) send(req *Request) (*Response, error) {
+	domain := ApiDomain
+	if weibo.baseURL != "" {
+		domain = weibo.baseURL
+	}
+	uri := fmt.Sprintf("%s/%s/%s%s", domain, ApiVersion, req.Endpoint, ApiNamePostfix)
+	var httpReq *http.Request
+	var err error
+	switch req.HTTPMethod {
+	case "get":
+		httpReq, err = newGetHttpRequest(uri, req.Token, req.Params)
+	case "post":
+		httpReq, err = newPostHttpRequest(uri, req.Token, req.Params, req.Upload)
+	default:
+		return nil, &ErrorString{"HTTP方法只能是\"get\"或者\"post\""}
 	}
+	if err != nil {
+		return nil, err
+	}
+	if weibo.tracer == nil {
+		return doHttpRequest(&weibo.httpClient, httpReq)
+	}
+	httpReq, endSpan := weibo.traceRequest(req, httpReq)
+	resp, err := doHttpRequest(&weibo.httpClient, httpReq)
+	endSpan(resp, err)
+	return resp, err
+}
 
-	// 发送请求
-	resp, err := weibo.httpClient.Do(req)
+// 发送HTTP请求并读取API服务器返回的全部内容
+//
+// 网络错误中的URL包含访问令牌，返回之前将其隐藏，见RedactURL函数。
+func doHttpRequest(client *http.Client, req *http.Request) (*Response, error) {
+	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = RedactURL(urlErr.URL)
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// 解析API服务器返回内容
-	bytes, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode == 200 {
-		err := json.Unmarshal(bytes, &response)
-		if err != nil {
-			return err
-		}
-		return nil
-	} else {
-		var weiboErr WeiboError
-		err := json.Unmarshal(bytes, &weiboErr)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// 生成GET请求
+//
+// 参数经过转义，因此可以包含空格、&、#、中文等字符。
+func newGetHttpRequest(uri string, token string, params Params) (*http.Request, error) {
+	query := params.values()
+	query.Set("access_token", token)
+	return http.NewRequest("GET", uri+"?"+query.Encode(), nil)
+}
+
+// 生成POST请求
+//
+// 当upload == nil时使用query string模式，否则使用流式的multipart，见UploadWithProgress函数注释。
+func newPostHttpRequest(uri string, token string, params Params, upload *Upload) (*http.Request, error) {
+	// 生成POST请求URI
+	requestUri := fmt.Sprintf("%s?access_token=%s", uri, url.QueryEscape(token))
+
+	if upload == nil {
+		// 无文件上传，因此POST body为简单的query string模式
+		pb := params.values()
+		pb.Set("access_token", token)
+		req, err := http.NewRequest("POST", requestUri, bytes.NewBufferString(pb.Encode()))
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return weiboErr
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
 	}
-	return nil
+
+	// 否则POST body使用multipart模式，图片内容不经缓存直接流式发送
+	body, err := newMultipartBody(params, upload.Reader, upload.Progress)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", requestUri, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", body.contentType)
+	if body.contentLength >= 0 {
+		// 已知长度时避免使用chunked编码
+		req.ContentLength = body.contentLength
+	}
+	return req, nil
 }